@@ -0,0 +1,86 @@
+package global
+
+import "context"
+import "crypto/tls"
+import "crypto/x509"
+import "net"
+import "net/http"
+import "os"
+import "path/filepath"
+import "time"
+import "golang.org/x/crypto/acme"
+import "golang.org/x/crypto/acme/autocert"
+
+
+type ACMEChallenge string
+const (
+    ACMEHTTP01      ACMEChallenge = "http-01"
+    ACMETLSALPN01   ACMEChallenge = "tls-alpn-01"
+)
+
+
+// ACMEOption contains options used to get certificates from an ACME CA, like Let's Encrypt.
+type ACMEOption struct {
+    Domains         []string        // Domain names the certificates are issued for.
+    Email           string          // Contact email of the ACME account, could be empty.
+    DirectoryURL    string          // ACME directory URL. Default is DefaultACMEDirectory.
+    CARoot          string          // Path of a PEM file of CA certificates used to verify the ACME server,
+                                    // e.g. the root certificate of Pebble. Empty means using the system roots.
+    CacheDir        string          // Directory to store the account key and certificates.
+    Challenge       ACMEChallenge   // Challenge type: http-01 (on the HTTP port) or tls-alpn-01 (on the HTTPS port).
+    RenewBefore     time.Duration   // Renew certificates before they expire. Default is DefaultACMERenewBefore.
+}
+
+const DefaultACMEDirectory = acme.LetsEncryptURL
+const DefaultACMERenewBefore = 30 * 24 * time.Hour
+
+
+// default directory to store ACME account key and certificates
+func defaultACMECacheDir() string {
+    dir, err := os.UserCacheDir()
+    if err != nil {
+        return "ran-acme"
+    }
+    return filepath.Join(dir, "ran", "acme")
+}
+
+
+// NewACMEManager creates an autocert.Manager which gets certificates from the ACME CA and renews them automatically.
+func NewACMEManager(option *ACMEOption) (m *autocert.Manager, err error) {
+    client := &acme.Client {
+        DirectoryURL: option.DirectoryURL,
+    }
+
+    if option.CARoot != "" {
+        var pool *x509.CertPool
+        pool, err = loadCertPool(option.CARoot)
+        if err != nil {
+            return
+        }
+
+        transport := http.DefaultTransport.(*http.Transport).Clone()
+        transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+        client.HTTPClient = &http.Client{Transport: transport}
+    }
+
+    whitelist := autocert.HostWhitelist(option.Domains...)
+
+    m = &autocert.Manager {
+        Prompt:         autocert.AcceptTOS,
+        Cache:          autocert.DirCache(option.CacheDir),
+
+        // the host of a http-01 challenge request may contain a port if the HTTP port is not 80
+        HostPolicy: func(ctx context.Context, host string) error {
+            if h, _, err := net.SplitHostPort(host); err == nil {
+                host = h
+            }
+            return whitelist(ctx, host)
+        },
+
+        RenewBefore:    option.RenewBefore,
+        Client:         client,
+        Email:          option.Email,
+    }
+
+    return
+}
@@ -1,9 +1,15 @@
 package global
 
+import "crypto/tls"
 import "os"
+import "errors"
+import "io/fs"
 import "fmt"
+import "io"
 import "flag"
+import "math"
 import "strings"
+import "time"
 import "path/filepath"
 import phelper "github.com/m3ng9i/go-utils/path"
 import "github.com/m3ng9i/ran/server"
@@ -19,10 +25,24 @@ const (
 
 // TLSOption contains options used in TLS encryption
 type TLSOption struct {
-    PublicKey   string          // Path of public key (certificate)
-    PrivateKey  string          // Path of private key
-    Port        uint            // HTTPS port. Default is DefaultTLSPort.
-    Policy      TLSPolicy       // TLS policy. Default is DefaultTLSPolicy.
+    PublicKey       string              // Path of public key (certificate)
+    PrivateKey      string              // Path of private key
+    Certificates    server.CertPairs    // More certificates, selected by the server name (SNI) of the TLS handshake.
+    CertDir         string              // Directory of more certificates, <name>.crt (or .pem, .cer) and <name>.key are a pair.
+    certDirPairs    server.CertPairs    // Certificates found in CertDir.
+    Auto            bool                // If true, create an in-memory self-signed certificate at startup,
+                                        // PublicKey and PrivateKey are not used.
+    Port            uint                // HTTPS port. Default is DefaultTLSPort.
+    Policy          TLSPolicy           // TLS policy. Default is DefaultTLSPolicy.
+    ACME            *ACMEOption         // If not nil, get certificates from an ACME CA, PublicKey and PrivateKey are not used.
+    CertWatch       time.Duration       // Interval of checking if the certificate files are changed. 0 means never reload.
+    ClientAuth      *ClientAuthOption   // If not nil, verify client certificates.
+    MinVersion      uint16              // Minimum TLS version, e.g. tls.VersionTLS12. 0 means the default of Go.
+    MaxVersion      uint16              // Maximum TLS version. 0 means the latest version supported by Go.
+    CipherSuites    []uint16            // Cipher suites of TLS 1.0-1.2, in order of preference. Empty means the default of Go.
+                                        // Cipher suites of TLS 1.3 are not configurable.
+    Curves          []tls.CurveID       // Elliptic curves used in key exchange, in order of preference.
+    NoSessionTickets bool               // If true, session ticket resumption is disabled.
 }
 
 const DefaultTLSPort uint = 443
@@ -32,12 +52,28 @@ const DefaultTLSPolicy = TLSOnly
 // Setting about ran server
 type Setting struct {
     IP              []string        // IP addresses binded to ran server.
+    BindWatch       time.Duration   // Interval of checking if addresses of the network interfaces in -bind-ip are changed.
+                                    // 0 means never.
+    bindIP          string          // Value of -bind-ip, it is resolved again when checking the network interfaces.
     Port            uint            // HTTP port. Default is 8080.
     ShowConf        bool            // If show config info in the log.
     Debug           bool            // If turns on debug mode. Default is false.
     TLS             *TLSOption      // If is nil, TLS is off.
+    MaxConns        uint            // Max number of simultaneous connections of all listeners. 0 means no limit.
+    MaxConnsPerIP   uint            // Max number of simultaneous connections per client IP. 0 means no limit.
+    HTTP2           HTTP2Option     // Options of HTTP/2.
+    HTTP3           bool            // If true, serve HTTP/3 (QUIC) on the UDP port of HTTPS.
+    Unix            *UnixSocketOption // If not nil, listen on Unix domain sockets instead of the HTTP port.
+    MetricsListen   string          // Address of a separate listener of metrics, <host>:<port> or unix:<path>.
+                                    // Empty means metrics are served on the site.
+    AdminListen     string          // Address of the admin API listener, <host>:<port> or unix:<path>. Empty means off.
+    AdminAuth       *server.Auth    // Basic authentication of the admin API, nil means no authentication.
     errorFile401    *string
     errorFile404    *string
+    errorFile503    *string
+    maintenanceFile *string
+    Files           fs.FS           // Files of Root, which is a directory or an archive.
+    isArchive       bool            // If Root is an archive file.
     server.Config
 }
 
@@ -47,10 +83,16 @@ type Setting struct {
 // p: path of 404 or 401 file, example: /404.html
 // name: name of the error file, 401 or 404
 func (this *Setting) checkCustomErrorFile(p, name string) (errorFile *server.ErrorFilePath, err error) {
-    newPath := filepath.Join(this.Root, p)
+    return checkErrorFile(this.Root, this.Files, this.isArchive, p, name)
+}
+
+
+// check a custom error file of a root, which is a directory or an archive (files is the content of the archive).
+func checkErrorFile(rootPath string, files fs.FS, isArchive bool, p, name string) (errorFile *server.ErrorFilePath, err error) {
+    newPath := filepath.Join(rootPath, p)
 
     // check if the custom error file is under root
-    root := this.Root
+    root := rootPath
     if !strings.HasSuffix(root, string(filepath.Separator)) {
         root = root + string(filepath.Separator)
     }
@@ -59,15 +101,26 @@ func (this *Setting) checkCustomErrorFile(p, name string) (errorFile *server.Err
         return
     }
 
+    relPath, _ := filepath.Rel(root, newPath)
+
     // check if the file path is exist and not a directory
-    e := phelper.IsExistFile(newPath)
-    if e != nil {
-        err = fmt.Errorf("'%s': %s", newPath, e)
-        return
+    if isArchive {
+        info, e := fs.Stat(files, filepath.ToSlash(relPath))
+        if e == nil && info.IsDir() {
+            e = errors.New("is a directory")
+        }
+        if e != nil {
+            err = fmt.Errorf("'%s' in the archive: %s", filepath.ToSlash(relPath), e)
+            return
+        }
+    } else {
+        e := phelper.IsExistFile(newPath)
+        if e != nil {
+            err = fmt.Errorf("'%s': %s", newPath, e)
+            return
+        }
     }
 
-    relPath, _ := filepath.Rel(root, newPath)
-
     errorFile = new(server.ErrorFilePath)
     errorFile.Abs = newPath
     errorFile.Rel = "/" + relPath
@@ -76,6 +129,110 @@ func (this *Setting) checkCustomErrorFile(p, name string) (errorFile *server.Err
 }
 
 
+// openSiteRoot checks the root of a virtual host or a mount, which is a directory or an archive.
+// It returns the absolute path and the files of the root, and if the root is an archive.
+func openSiteRoot(root string) (abs string, files fs.FS, isArchive bool, err error) {
+    info, err := os.Stat(root)
+    if err != nil {
+        return
+    }
+    isArchive = !info.IsDir()
+    if isArchive && !server.IsArchive(root) {
+        err = errors.New("Root is not a directory or an archive")
+        return
+    }
+    abs, err = filepath.Abs(root)
+    if err != nil {
+        err = fmt.Errorf("Can not convert root to absolute form: %s", err)
+        return
+    }
+    files, err = server.OpenRoot(abs)
+    if err != nil {
+        err = fmt.Errorf("Open archive '%s' error: %s", abs, err)
+    }
+    return
+}
+
+
+// check roots and custom error files of the virtual hosts, and if a host name is used more than once.
+func (this *Setting) checkVirtualHosts() (errmsg []string) {
+    hosts := make(map[string]bool)
+    var defaults int
+
+    for _, vh := range this.VirtualHosts {
+        name := strings.Join(vh.Hosts, ",")
+
+        if len(vh.Hosts) == 0 {
+            errmsg = append(errmsg, fmt.Sprintf("Host name of virtual host '%s' should be provided", vh.Root))
+        }
+        for _, host := range vh.Hosts {
+            if hosts[host] {
+                errmsg = append(errmsg, fmt.Sprintf("Host name '%s' is used by more than one virtual host", host))
+            }
+            hosts[host] = true
+        }
+        if vh.Default {
+            defaults++
+        }
+
+        for _, index := range vh.IndexName {
+            if filepath.Base(index) != index {
+                errmsg = append(errmsg, fmt.Sprintf("Virtual host '%s': Filename of index can not include path separators", name))
+                break
+            }
+        }
+
+        var isArchive bool
+        var err error
+        vh.Root, vh.Files, isArchive, err = openSiteRoot(vh.Root)
+        if err != nil {
+            errmsg = append(errmsg, fmt.Sprintf("Virtual host '%s': %s", name, err))
+            continue
+        }
+
+        if vh.ErrorFile404 != "" {
+            vh.Path404, err = checkErrorFile(vh.Root, vh.Files, isArchive, vh.ErrorFile404, "404")
+            if err != nil {
+                errmsg = append(errmsg, fmt.Sprintf("Virtual host '%s': %s", name, err))
+            }
+        }
+        if vh.ErrorFile401 != "" {
+            vh.Path401, err = checkErrorFile(vh.Root, vh.Files, isArchive, vh.ErrorFile401, "401")
+            if err != nil {
+                errmsg = append(errmsg, fmt.Sprintf("Virtual host '%s': %s", name, err))
+            }
+        }
+    }
+
+    if defaults > 1 {
+        errmsg = append(errmsg, "Only one virtual host could be the default")
+    }
+
+    return
+}
+
+
+// check roots of the mounts, and if a prefix is used more than once.
+func (this *Setting) checkMounts() (errmsg []string) {
+    prefixes := make(map[string]bool)
+
+    for _, m := range this.Mounts {
+        if prefixes[m.Prefix] {
+            errmsg = append(errmsg, fmt.Sprintf("Mount prefix '%s' is used more than once", m.Prefix))
+        }
+        prefixes[m.Prefix] = true
+
+        var err error
+        m.Root, m.Files, _, err = openSiteRoot(m.Root)
+        if err != nil {
+            errmsg = append(errmsg, fmt.Sprintf("Mount '%s': %s", m.Prefix, err))
+        }
+    }
+
+    return
+}
+
+
 func (this *Setting) check() (errmsg []string) {
 
     if this.Port > 65535 || this.Port <= 0 {
@@ -100,8 +257,8 @@ func (this *Setting) check() (errmsg []string) {
         }
         return
     } else {
-        if info.IsDir() == false {
-            errmsg = append(errmsg, "Root is not a directory")
+        if info.IsDir() == false && !server.IsArchive(this.Root) {
+            errmsg = append(errmsg, "Root is not a directory or an archive (.zip, .tar, .tar.gz or .tgz)")
             return
         }
 
@@ -110,6 +267,13 @@ func (this *Setting) check() (errmsg []string) {
             errmsg = append(errmsg, fmt.Sprintf("Can not convert root to absolute form: %s", err.Error()))
             return
         }
+
+        this.Files, err = server.OpenRoot(this.Root)
+        if err != nil {
+            errmsg = append(errmsg, fmt.Sprintf("Open archive '%s' error: %s", this.Root, err.Error()))
+            return
+        }
+        this.isArchive = !info.IsDir()
     }
 
     if this.errorFile404 != nil {
@@ -119,6 +283,39 @@ func (this *Setting) check() (errmsg []string) {
         }
     }
 
+    if this.Maintenance != nil {
+        if this.errorFile503 != nil {
+            this.Maintenance.Path503, err = this.checkCustomErrorFile(*this.errorFile503, "503")
+            if err != nil {
+                errmsg = append(errmsg, err.Error())
+            }
+        }
+
+        // the marker file is not required to exist, it's created when maintenance begins
+        if this.maintenanceFile != nil && this.isArchive {
+            errmsg = append(errmsg, "-maintenance-file cannot be used if Root is an archive")
+        } else if this.maintenanceFile != nil {
+            root := this.Root
+            if !strings.HasSuffix(root, string(filepath.Separator)) {
+                root = root + string(filepath.Separator)
+            }
+            file := filepath.Join(this.Root, *this.maintenanceFile)
+            if !strings.HasPrefix(file, root) {
+                errmsg = append(errmsg, "Path of maintenance file can not be out of root directory")
+            } else if p := "/" + filepath.ToSlash(file[len(root):]); this.ServeAll || !strings.Contains(p, "/.") {
+                // paths start with dot are not served, so clients could not see the marker file
+                errmsg = append(errmsg, "Name of maintenance file should start with dot and -serve-all should be false, " +
+                    "otherwise the file is served")
+            } else {
+                this.Maintenance.File = file
+            }
+        }
+
+        if this.Maintenance.RetryAfter < 0 {
+            errmsg = append(errmsg, "Value of maintenance retry after cannot be negative")
+        }
+    }
+
     if this.Auth != nil {
         if this.Auth.Username == "" || this.Auth.Password == "" {
             errmsg = append(errmsg, "Username or password cannot be empty string")
@@ -142,18 +339,165 @@ func (this *Setting) check() (errmsg []string) {
         }
     }
 
-    if this.TLS != nil {
-        if this.TLS.PublicKey == "" || this.TLS.PrivateKey == "" {
+    if this.RateLimit != nil {
+        if this.RateLimit.Requests < 0 {
+            errmsg = append(errmsg, "Value of rate limit cannot be negative")
+        }
+        for _, p := range this.RateLimit.Paths {
+            if !strings.HasPrefix(p.Prefix, "/") {
+                errmsg = append(errmsg, fmt.Sprintf(`Rate limit path must start with "/", got %s`, p.Prefix))
+            }
+        }
+    }
+
+    if this.HTTP3 {
+        if this.TLS == nil {
+            errmsg = append(errmsg, "HTTP/3 needs TLS")
+        } else if this.TLS.MaxVersion > 0 && this.TLS.MaxVersion < tls.VersionTLS13 {
+            errmsg = append(errmsg, "HTTP/3 needs TLS 1.3, the maximum TLS version cannot be lower")
+        }
+    }
+
+    if this.HTTP2.MaxConcurrentStreams > math.MaxUint32 {
+        errmsg = append(errmsg, "Max HTTP/2 concurrent streams is too large")
+    }
+    if this.HTTP2.MaxReadFrameSize > 0 &&
+        (this.HTTP2.MaxReadFrameSize < minHTTP2FrameSize || this.HTTP2.MaxReadFrameSize > maxHTTP2FrameSize) {
+        errmsg = append(errmsg, fmt.Sprintf("Max HTTP/2 frame size should be between %d and %d",
+            minHTTP2FrameSize, maxHTTP2FrameSize))
+    }
+
+    if this.BindWatch < 0 {
+        errmsg = append(errmsg, "Value of bind watch cannot be negative")
+    } else if this.BindWatch > 0 && !hasInterfaceName(this.bindIP) {
+        errmsg = append(errmsg, "-bind-watch needs names of network interfaces in -bind-ip")
+    }
+
+    if this.Unix != nil {
+        if len(this.Unix.Paths) == 0 {
+            errmsg = append(errmsg, "Path of Unix socket should be provided")
+        }
+        if this.Unix.Owner != "" {
+            if _, _, err := lookupOwner(this.Unix.Owner); err != nil {
+                errmsg = append(errmsg, fmt.Sprintf("Invalid owner of Unix sockets '%s': %s", this.Unix.Owner, err))
+            }
+        }
+        // requests from the sockets have no TLS connection state, they never pass the check of client certificates
+        if this.TLS != nil && this.TLS.ClientAuth != nil && this.TLS.ClientAuth.Mode == ClientAuthRequired {
+            errmsg = append(errmsg, `-listen cannot be used with client auth "required", client certificates of ` +
+                "the Unix sockets should be verified by the reverse proxy")
+        }
+    }
+
+    if this.Metrics != nil {
+        if !strings.HasPrefix(this.Metrics.Path, "/") {
+            errmsg = append(errmsg, fmt.Sprintf(`Metrics path must start with "/", got %s`, this.Metrics.Path))
+        }
+        if this.MetricsListen != "" {
+            if err := checkListenAddr(this.MetricsListen); err != nil {
+                errmsg = append(errmsg, err.Error())
+            }
+        }
+    }
+
+    if this.LiveReload != nil && this.isArchive {
+        errmsg = append(errmsg, "-live-reload cannot be used if Root is an archive")
+    }
+
+    if this.AdminListen != "" {
+        if err := checkListenAddr(this.AdminListen); err != nil {
+            errmsg = append(errmsg, err.Error())
+        } else if this.AdminAuth == nil && !isLocalAddr(this.AdminListen) {
+            errmsg = append(errmsg, "-admin-auth is required if the admin API listens on a non-loopback address")
+        }
+    }
+
+    if this.Health != nil {
+        // the trailing slash is removed, so "/" becomes empty
+        if !strings.HasPrefix(this.Health.Prefix, "/") {
+            errmsg = append(errmsg, `Health path prefix must start with "/" and cannot be "/"`)
+        }
+    }
+
+    if this.Proxy != nil && this.Proxy.Timeout <= 0 {
+        errmsg = append(errmsg, "Proxy timeout must be greater than 0")
+    }
+
+    if len(this.VirtualHosts) > 0 {
+        errmsg = append(errmsg, this.checkVirtualHosts()...)
+    }
+
+    if len(this.Mounts) > 0 {
+        errmsg = append(errmsg, this.checkMounts()...)
+    }
+
+    if this.TLS != nil && this.TLS.ACME != nil {
+        acme := this.TLS.ACME
+        if this.TLS.PublicKey != "" || this.TLS.PrivateKey != "" ||
+            len(this.TLS.Certificates) > 0 || this.TLS.CertDir != "" {
+            errmsg = append(errmsg, "Certificate and private key cannot be used with ACME")
+        }
+        if len(acme.Domains) == 0 {
+            errmsg = append(errmsg, "ACME domain should be provided")
+        }
+        if acme.DirectoryURL == "" {
+            errmsg = append(errmsg, "ACME directory URL cannot be empty")
+        }
+        if acme.CARoot != "" {
+            if err := phelper.IsNonEmptyFile(acme.CARoot); err != nil {
+                errmsg = append(errmsg, fmt.Sprintf("'%s': %s", acme.CARoot, err))
+            }
+        }
+        if acme.Challenge != ACMEHTTP01 && acme.Challenge != ACMETLSALPN01 {
+            errmsg = append(errmsg, `Value of ACME challenge could only be "http-01" or "tls-alpn-01"`)
+        }
+        if acme.Challenge == ACMEHTTP01 && this.TLS.Policy == TLSOnly {
+            errmsg = append(errmsg, `ACME http-01 challenge needs the HTTP port, use it with TLS policy "redirect" or "both"`)
+        }
+        if acme.RenewBefore <= 0 {
+            errmsg = append(errmsg, "ACME renew before must be greater than 0")
+        }
+        if this.TLS.Auto {
+            errmsg = append(errmsg, "-tls-auto cannot be used with ACME")
+        }
+    } else if this.TLS != nil {
+        if this.TLS.Auto {
+            if this.TLS.PublicKey != "" || this.TLS.PrivateKey != "" {
+                errmsg = append(errmsg, "Certificate and private key cannot be used with -tls-auto")
+            }
+        } else if this.TLS.PublicKey != "" || this.TLS.PrivateKey != "" {
+            if this.TLS.PublicKey == "" || this.TLS.PrivateKey == "" {
+                errmsg = append(errmsg, "Both certificate path and key path should be provided")
+            }
+        } else if len(this.TLS.Certificates) == 0 && this.TLS.CertDir == "" {
             errmsg = append(errmsg, "Both certificate path and key path should be provided")
-        } else {
-            if err := phelper.IsNonEmptyFile(this.TLS.PublicKey); err != nil {
-                errmsg = append(errmsg, fmt.Sprintf("'%s': %s", this.TLS.PublicKey, err))
+        }
+
+        if this.TLS.Auto && (len(this.TLS.Certificates) > 0 || this.TLS.CertDir != "") {
+            errmsg = append(errmsg, "-cert-pair and -cert-dir cannot be used with -tls-auto")
+        }
+
+        if this.TLS.CertDir != "" {
+            var err error
+            this.TLS.certDirPairs, err = server.CertDirPairs(this.TLS.CertDir)
+            if err != nil {
+                errmsg = append(errmsg, err.Error())
             }
-            if err := phelper.IsNonEmptyFile(this.TLS.PrivateKey); err != nil {
-                errmsg = append(errmsg, fmt.Sprintf("'%s': %s", this.TLS.PrivateKey, err))
+        }
+
+        if !this.TLS.Auto {
+            for _, pair := range this.TLS.CertPairs() {
+                if err := phelper.IsNonEmptyFile(pair.Cert); err != nil {
+                    errmsg = append(errmsg, fmt.Sprintf("'%s': %s", pair.Cert, err))
+                }
+                if err := phelper.IsNonEmptyFile(pair.Key); err != nil {
+                    errmsg = append(errmsg, fmt.Sprintf("'%s': %s", pair.Key, err))
+                }
             }
         }
+    }
 
+    if this.TLS != nil {
         if this.TLS.Port > 65535 || this.TLS.Port <= 0 {
             errmsg = append(errmsg, "Available HTTPS port range is 1-65535")
         }
@@ -163,8 +507,51 @@ func (this *Setting) check() (errmsg []string) {
             return // ignore the following checking
         }
 
-        if this.TLS.Policy != TLSOnly && this.TLS.Port == this.Port {
-            errmsg = append(errmsg, "HTTP port and HTTPS port cannot be the same.")
+        if this.TLS.MinVersion > 0 && this.TLS.MaxVersion > 0 && this.TLS.MinVersion > this.TLS.MaxVersion {
+            errmsg = append(errmsg, "Minimum TLS version cannot be greater than maximum TLS version")
+        }
+        if this.TLS.ACME != nil && this.TLS.ACME.Challenge == ACMETLSALPN01 &&
+            this.TLS.MaxVersion > 0 && this.TLS.MaxVersion < tls.VersionTLS12 {
+            errmsg = append(errmsg, "ACME tls-alpn-01 challenge needs TLS 1.2 or above")
+        }
+        if this.TLS.MinVersion == tls.VersionTLS13 && len(this.TLS.CipherSuites) > 0 {
+            errmsg = append(errmsg, "Cipher suites cannot be used when the minimum TLS version is 1.3")
+        }
+        if len(this.TLS.CipherSuites) > 0 && !hasHTTP2CipherSuite(this.TLS.CipherSuites) {
+            errmsg = append(errmsg, "Cipher suites should include TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 or " +
+                "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256, which is required by HTTP/2")
+        }
+    }
+
+    if this.HSTS != nil {
+        if this.TLS == nil {
+            errmsg = append(errmsg, "HSTS needs TLS")
+        } else if this.HSTS.Preload {
+            // requirements of https://hstspreload.org
+            if this.HSTS.MaxAge < server.HSTSPreloadMinAge || !this.HSTS.IncludeSubDomains {
+                errmsg = append(errmsg, "HSTS preload needs a max age of at least 1 year and includeSubDomains")
+            }
+            if this.TLS.Policy == TLSBoth {
+                errmsg = append(errmsg, `HSTS preload needs TLS policy "redirect" or "only"`)
+            }
+        }
+    }
+
+    if this.TLS != nil && this.TLS.ClientAuth != nil {
+        clientAuth := this.TLS.ClientAuth
+        if err := phelper.IsNonEmptyFile(clientAuth.CA); err != nil {
+            errmsg = append(errmsg, fmt.Sprintf("'%s': %s", clientAuth.CA, err))
+        }
+        if clientAuth.Mode != ClientAuthOptional && clientAuth.Mode != ClientAuthRequired {
+            errmsg = append(errmsg, `Value of client auth could only be "optional" or "required"`)
+        }
+        if clientAuth.Mode == ClientAuthOptional && len(clientAuth.Paths) > 0 {
+            errmsg = append(errmsg, `Client auth path can only be used with client auth "required"`)
+        }
+        for _, p := range clientAuth.Paths {
+            if !strings.HasPrefix(p, "/") {
+                errmsg = append(errmsg, fmt.Sprintf(`Client auth path must start with "/", got %s`, p))
+            }
         }
     }
 
@@ -174,14 +561,51 @@ func (this *Setting) check() (errmsg []string) {
 
 func (this *Setting) String() string {
 
+    // Debug and the settings of the site could be changed by the admin API
+    configMu.RLock()
+    defer configMu.RUnlock()
+
 https := `TLS: on
+Auto certificate: %t
 Certificate: %s
 Private key: %s
 TLS port: %d
-TLS policy: %s`
+TLS policy: %s
+Certificate watch interval: %s
+TLS version: %s - %s
+Session tickets: %t`
 
     if this.TLS != nil {
-        https = fmt.Sprintf(https, this.TLS.PublicKey, this.TLS.PrivateKey, this.TLS.Port, this.TLS.Policy)
+        https = fmt.Sprintf(https, this.TLS.Auto, this.TLS.PublicKey, this.TLS.PrivateKey, this.TLS.Port, this.TLS.Policy,
+            this.TLS.CertWatch, tlsVersionName(this.TLS.MinVersion), tlsVersionName(this.TLS.MaxVersion),
+            !this.TLS.NoSessionTickets)
+        if len(this.TLS.Certificates) > 0 {
+            https += "\nCertificate pairs: " + this.TLS.Certificates.String()
+        }
+        if this.TLS.CertDir != "" {
+            https += fmt.Sprintf("\nCertificate directory: %s (%d pairs)", this.TLS.CertDir, len(this.TLS.certDirPairs))
+        }
+        if len(this.TLS.CipherSuites) > 0 {
+            https += "\nCipher suites: " + cipherSuiteNames(this.TLS.CipherSuites)
+        }
+        if len(this.TLS.Curves) > 0 {
+            https += "\nCurves: " + curveNames(this.TLS.Curves)
+        }
+        if this.HSTS != nil {
+            https += "\nHSTS: " + this.HSTS.String()
+        }
+        if this.TLS.ACME != nil {
+            https += fmt.Sprintf("\nACME: %s (directory: %s, challenge: %s, cache: %s)",
+                strings.Join(this.TLS.ACME.Domains, ", "), this.TLS.ACME.DirectoryURL,
+                this.TLS.ACME.Challenge, this.TLS.ACME.CacheDir)
+        }
+        if this.TLS.ClientAuth != nil {
+            https += fmt.Sprintf("\nClient auth: %s (CA: %s", this.TLS.ClientAuth.Mode, this.TLS.ClientAuth.CA)
+            if len(this.TLS.ClientAuth.Paths) > 0 {
+                https += ", paths: " + strings.Join(this.TLS.ClientAuth.Paths, ", ")
+            }
+            https += ")"
+        }
     } else {
         https = "TLS: off"
     }
@@ -193,13 +617,32 @@ TLS policy: %s`
 
 s := `Root: %s
 Port: %d
+BindWatch: %s
 Path404: %s
 IndexName: %s
 ListDir: %t
 ServeAll: %t
 Gzip: %t
 NoCache: %t
+CachePolicy: %s
+CacheHashed: %t
 CORS: %t
+RateLimit: %s
+MaxConns: %d
+MaxConnsPerIP: %d
+H2C: %t
+HTTP2MaxConcurrentStreams: %d
+HTTP2MaxReadFrameSize: %d
+HTTP3: %t
+UnixSockets: %s
+Metrics: %s
+Health: %s
+Maintenance: %s
+LiveReload: %s
+Admin: %s
+Proxy: %s
+VirtualHosts: %s
+Mounts: %s
 Debug: %t
 Auth: %s
 Path401: %s
@@ -215,16 +658,123 @@ Path401: %s
         path401 = this.Path401.Rel
     }
 
+    rateLimit := "<None>"
+    if this.RateLimit != nil {
+        rateLimit = this.RateLimit.String()
+    }
+
+    unix := "<None>"
+    if this.Unix != nil {
+        unix = strings.Join(this.Unix.Paths, ", ")
+        if this.Unix.Mode != 0 {
+            unix += fmt.Sprintf(" (mode: %04o)", uint32(this.Unix.Mode))
+        }
+        if this.Unix.Owner != "" {
+            unix += fmt.Sprintf(" (owner: %s)", this.Unix.Owner)
+        }
+    }
+
+    metrics := "<None>"
+    if this.Metrics != nil {
+        metrics = this.Metrics.Path
+        if this.MetricsListen != "" {
+            metrics += " (listen: " + this.MetricsListen + ")"
+        }
+    }
+
+    health := "<None>"
+    if this.Health != nil {
+        health = this.Health.Prefix
+        if this.Health.NoLog {
+            health += " (not logged)"
+        }
+    }
+
+    maintenance := "<None>"
+    if this.Maintenance != nil {
+        maintenance = "off"
+        if this.Maintenance.On {
+            maintenance = "on"
+        }
+        if this.Maintenance.File != "" {
+            maintenance += " (file: " + this.Maintenance.File + ")"
+        }
+        if this.Maintenance.Path503 != nil {
+            maintenance += " (503: " + this.Maintenance.Path503.Rel + ")"
+        }
+        if this.Maintenance.RetryAfter > 0 {
+            maintenance += fmt.Sprintf(" (retry after: %s)", this.Maintenance.RetryAfter)
+        }
+        if len(this.Maintenance.Allow) > 0 {
+            maintenance += " (allow: " + this.Maintenance.Allow.String() + ")"
+        }
+    }
+
+    liveReload := "<None>"
+    if this.LiveReload != nil {
+        liveReload = "on"
+        if len(this.LiveReload.Ignore) > 0 {
+            liveReload += " (ignore: " + strings.Join(this.LiveReload.Ignore, ", ") + ")"
+        }
+    }
+
+    admin := "<None>"
+    if this.AdminListen != "" {
+        admin = this.AdminListen
+        if this.AdminAuth != nil {
+            admin += " (auth: on)"
+        }
+    }
+
+    proxy := "<None>"
+    if this.Proxy != nil {
+        proxy = this.Proxy.String()
+    }
+
+    vhosts := "<None>"
+    if len(this.VirtualHosts) > 0 {
+        vhosts = this.VirtualHosts.String()
+    }
+
+    mounts := "<None>"
+    if len(this.Mounts) > 0 {
+        mounts = this.Mounts.String()
+    }
+
+    cachePolicy := "<None>"
+    if len(this.CachePolicy) > 0 {
+        cachePolicy = this.CachePolicy.String()
+    }
+
     s = fmt.Sprintf(s,
                     this.Root,
                     this.Port,
+                    this.BindWatch,
                     path404,
                     strings.Join(this.IndexName, ", "),
                     this.ListDir,
                     this.ServeAll,
                     this.Gzip,
                     this.NoCache,
+                    cachePolicy,
+                    this.CacheHashed,
                     this.CORS,
+                    rateLimit,
+                    this.MaxConns,
+                    this.MaxConnsPerIP,
+                    this.HTTP2.H2C,
+                    this.HTTP2.MaxConcurrentStreams,
+                    this.HTTP2.MaxReadFrameSize,
+                    this.HTTP3,
+                    unix,
+                    metrics,
+                    health,
+                    maintenance,
+                    liveReload,
+                    admin,
+                    proxy,
+                    vhosts,
+                    mounts,
                     this.Debug,
                     auth,
                     path401,
@@ -265,10 +815,26 @@ Usage: ran [Options...]
 Options:
 
     -r,  -root=<path>           Root path of the site. Default is current working directory.
+                                A .zip, .tar, .tar.gz or .tgz file could also be used, the site is served
+                                from the archive without extracting it.
     -b,  -bind-ip=<ip>          Bind one or more IP addresses to the ran web server.
                                 Multiple IP addresses should be separated by comma.
+                                Names of network interfaces could also be used, e.g. eth0,wlan0,
+                                the current IPv4 and IPv6 addresses of the interfaces are bound.
                                 If not provide this Option, ran will use 0.0.0.0.
+         -bind-watch=<dur>      Interval of checking if addresses of the network interfaces in -bind-ip are changed,
+                                e.g. renewed by DHCP. New addresses are listened and removed addresses are closed
+                                without a restart. Default is 0 (not check).
     -p,  -port=<port>           HTTP port. Default is 8080.
+         -listen=<addr>         Listen on Unix domain sockets instead of the HTTP port, in the form of unix:<path>.
+                                Multiple addresses should be separated by comma. Example: -listen unix:/run/ran.sock
+                                Requests from the sockets are always served, not redirected to HTTPS,
+                                TLS should be terminated by the reverse proxy in front of ran. Client certificates
+                                and HSTS of the sockets are handled by the proxy, client auth "required" cannot be used.
+                                If TLS is on, the HTTPS port is still enabled.
+         -listen-mode=<mode>    File mode of the Unix domain sockets in octal, e.g. 0660. Default is decided by umask.
+         -listen-owner=<owner>  Owner of the Unix domain sockets in the form of <user>[:<group>].
+                                Example: -listen-owner www-data:www-data
          -404=<path>            Path of a custom 404 file, relative to Root. Example: /404.html.
     -i,  -index=<path>          File name of index, priority depends on the order of values.
                                 Separate by colon. Example: -i "index.html:index.htm"
@@ -286,6 +852,17 @@ Options:
                                     Expires 0
                                 Default is false.
 
+         -cache=<rule>          Set a Cache-Control rule in the form of <pattern>[,<pattern>...]=<value>.
+                                This option could be used more than once, the first matched rule takes effect.
+                                Pattern could be a file extension (.html), a path prefix (/assets/),
+                                a path pattern (/js/*.js) or a file name pattern (*.min.js).
+                                Value could be a Cache-Control value, "immutable", or a period like 12h, 1d, 1y.
+                                Example: -cache ".html=no-cache" -cache ".png,.jpg=1d"
+         -cache-hashed=<bool>   Mark files with a content hash in the name (like app.3f2a9c1b.js) as immutable:
+                                    Cache-Control: public, max-age=31536000, immutable
+                                Rules set by -cache have higher priority. Default is false.
+                                -no-cache overrides -cache and -cache-hashed.
+
          -cors=<bool>           If true, ran will write some cross-origin resource sharing headers to the response:
                                     Access-Control-Allow-Origin: *
                                     Access-Control-Allow-Credentials: true
@@ -301,6 +878,139 @@ Options:
                                 If authentication fails and 401 file is set,
                                 the file content will be sent to the client.
 
+         -rate-limit=<n>        Max number of requests per second per client IP. Default is 0 (no limit).
+                                Requests exceeding the limit get a 429 response with a Retry-After header.
+         -rate-burst=<n>        Max number of requests a client could send at once.
+                                Default is the value of -rate-limit rounded up.
+         -bandwidth=<size>      Max bytes per second sent to a client IP, e.g. 512K, 2M. Default is 0 (no limit).
+         -global-bandwidth=<size>
+                                Max bytes per second sent to all clients. Default is 0 (no limit).
+         -rate-path=<rule>      Override -rate-limit and -bandwidth for paths start with a prefix,
+                                in the form of <prefix>=<requests>[,<bandwidth>]. 0 means no limit.
+                                This option could be used more than once. Example: -rate-path "/downloads/=2,1M"
+         -max-conns=<n>         Max number of simultaneous connections of all listeners. Default is 0 (no limit).
+         -max-conns-per-ip=<n>  Max number of simultaneous connections per client IP. Default is 0 (no limit).
+                                Connections exceeding the limits are closed, the number of them is logged every
+                                minute, and every rejected connection is logged in debug mode.
+         -h2c                   Accept HTTP/2 without TLS (h2c) on the HTTP port, e.g. behind an h2c-capable proxy.
+                                Only prior knowledge is supported, the Upgrade header is deprecated by RFC 9113.
+         -http2-max-streams=<n> Max number of concurrent HTTP/2 streams per connection. Default is 250.
+         -http2-max-frame-size=<n>
+                                Max size of HTTP/2 frames could be read, 16384 to 16777215. Default is 1048576.
+         -http3                 Serve HTTP/3 (QUIC) on the UDP port with the same number as the HTTPS port.
+                                It is advertised to the browsers by the Alt-Svc header of HTTPS responses.
+                                Connection limits do not apply to HTTP/3. Needs TLS 1.3.
+
+         -metrics=<path>        Serve metrics in the Prometheus text format on the path of the site, e.g. /metrics.
+                                Metrics include requests by status class and method, bytes sent, response time,
+                                gzip compression ratio, authentication failures, requests in flight
+                                and open connections of every listener. Metrics are protected by -auth.
+         -metrics-listen=<addr> Serve metrics on a separate listener instead of the site, in the form of
+                                <host>:<port> or unix:<path>. Example: -metrics-listen 127.0.0.1:9100
+                                Path of metrics is set by -metrics, default is /metrics.
+         -health=<prefix>       Serve health endpoints under the path prefix, e.g. /_ran:
+                                    <prefix>/live       always returns 200 if ran is running
+                                    <prefix>/ready      returns 503 if the root directory is not readable,
+                                                        maintenance mode is on or the certificate is expired,
+                                                        otherwise returns 200
+                                    <prefix>/version    returns version, branch, commit id and build time
+                                Responses are in JSON. The endpoints are not protected by -auth, -client-ca
+                                and -rate-limit.
+         -health-log=<bool>     Write requests of the health endpoints to the access log. Default is true.
+
+         -maintenance           Turn on maintenance mode at startup, all requests get 503 Service Unavailable.
+                                Maintenance mode could also be turned on or off by sending SIGUSR1 to ran
+                                (not supported on Windows), or by the admin API.
+         -maintenance-file=<path>
+                                Maintenance mode is on while the file exists, relative to Root. The file is checked
+                                every second, it need not exist at startup. The name should start with dot and
+                                -serve-all should be false, so the file is not served. Example: -maintenance-file /.maintenance
+         -503=<path>            Path of a custom 503 file shown in maintenance mode, relative to Root.
+                                Example: /503.html.
+         -maintenance-retry-after=<dur>
+                                Value of the Retry-After header in maintenance mode. 0 means the header is not sent.
+                                Default is 5m.
+         -maintenance-allow=<ips>
+                                IP addresses or networks which bypass maintenance mode, separate by comma.
+                                This option could be used more than once. Example: -maintenance-allow 10.0.0.0/8
+
+         -live-reload           Watch Root recursively and reload the pages in browsers when files are changed,
+                                for local web development. A script is injected into HTML files served by ran,
+                                it receives the changes by Server-Sent Events from /.ran/livereload.
+                                If only CSS files are changed, the stylesheets are reloaded without reloading the page.
+         -live-reload-ignore=<patterns>
+                                Paths which are not watched by live reload, separate by comma. A pattern starts
+                                with "/" is a path relative to Root, other patterns are matched against file and
+                                directory names. This option could be used more than once.
+                                Paths start with dot are not watched unless -serve-all is true.
+                                Example: -live-reload-ignore "/build,node_modules,*.tmp"
+
+         -admin=<addr>          Serve the admin API on a separate listener, in the form of <host>:<port>
+                                or unix:<path>. Example: -admin 127.0.0.1:9900
+                                The admin API accepts and returns JSON:
+                                    GET  /config        effective config, the same as -showconf
+                                    GET  /connections   open connections of HTTP and HTTPS listeners
+                                    GET  /maintenance   if maintenance mode is on
+                                    PUT  /maintenance   turn on or off maintenance mode, body: {"enabled": true}
+                                    POST /reload        reload the config file, the command line and the certificates
+                                    GET  /log-level     current log level
+                                    PUT  /log-level     change log level, body: {"level": "debug"} or {"level": "info"}
+                                /reload changes the settings of the site, like -root, -auth, -proxy, -vhost and
+                                -mount. Options of the listeners, TLS, metrics, health checks, maintenance mode,
+                                live reload and the admin API need a restart.
+         -admin-auth=<user:pass>
+                                Username and password of the admin API, basic authentication is used.
+                                Required if -admin is not a loopback address or a Unix socket.
+
+         -proxy=<route>         Forward requests under a path prefix to a backend before serving static files,
+                                in the form of <prefix>=<url>[;pass-auth]. This option could be used more than once.
+                                WebSocket connections are supported. Example: -proxy /api=http://127.0.0.1:9000
+                                If -auth is set, the Authorization header of the client is not sent to the backend,
+                                unless the route has the pass-auth option.
+         -proxy-strip-prefix=<bool>
+                                Remove the prefix from the path before forwarding. Default is false.
+         -proxy-preserve-host=<bool>
+                                Send the Host header of the client to the backend.
+                                If false, the host of the backend url is used. Default is false.
+         -proxy-timeout=<dur>   Timeout of connecting to the backend and waiting for the response headers.
+                                Default is 30s.
+         -proxy-header=<header> Set a header to the requests sent to the backend, in the form of "<name>: <value>".
+                                This option could be used more than once.
+                                X-Forwarded-For, X-Forwarded-Host, X-Forwarded-Proto and X-Request-Id are always sent.
+
+         -vhost=<vhost>         Serve requests of some host names from a separate root, in the form of
+                                <hosts>=<root>[;<option>...]. Host names are separated by comma, a name starts
+                                with "*." matches all the sub domains. This option could be used more than once.
+                                Requests of other hosts are served by Root and the options above.
+                                Options of a virtual host, separated by semicolon:
+                                    index=<names>           file names of index, separate by colon
+                                    listdir[=<bool>]        show file list of a directory
+                                    serve-all[=<bool>]      serve paths start with dot
+                                    auth=<user:pass>        username and password of authentication
+                                    auth-method=<method>    basic or digest, default is basic
+                                    404=<path>              custom 404 file, relative to the root of the host
+                                    401=<path>              custom 401 file, relative to the root of the host
+                                    header=<name: value>    header set to the responses, could be used more than once
+                                    default                 serve requests of the hosts not matched by -vhost
+                                Gzip, cache and CORS options are the same as the main site. Proxy routes and metrics
+                                are served by the main site only, they are not served on a virtual host.
+                                Example: -vhost "docs.example.com,*.docs.example.com=/srv/docs;listdir"
+
+         -mount=<mount>         Serve a separate root under a URL prefix, in the form of <prefix>=<root>[;<option>...].
+                                The root could be a directory or an archive. This option could be used more than once,
+                                the longest matched prefix takes effect. Mount points are shown as directories in the
+                                file list of their parents. Options of a mount, separated by semicolon:
+                                    listdir[=<bool>]        show file list of a directory
+                                    serve-all[=<bool>]      serve paths start with dot
+                                    auth=<user:pass>        username and password of authentication, "none" turns off
+                                                            the authentication of -auth for the mount
+                                    auth-method=<method>    basic or digest, default is basic
+                                -listdir and -serve-all are not inherited from the main site. Authentication of -auth
+                                protects the mount, unless the auth option is set. Index, gzip, cache, CORS and 404
+                                options are the same as the main site. Proxy routes and metrics are not served under
+                                the prefix of a mount.
+                                Example: -mount "/downloads=/srv/artifacts;listdir"
+
          -tls-port=<port>       HTTPS port. Default is 443.
          -tls-policy=<pol>      This option indicates how to handle HTTP and HTTPS traffic.
                                 There are three option values: redirect, both and only.
@@ -308,17 +1018,102 @@ Options:
                                 both:     both HTTP and HTTPS are enabled
                                 only:     only HTTPS is enabled, HTTP is disabled
                                 The default value is: only.
+                                With redirect or both, set -tls-port to the same value as -port to serve HTTP and
+                                HTTPS on one port, connections are told apart by the first byte of the request.
          -cert=<path>           Load a file as a certificate.
                                 If use with -make-cert, will generate a certificate to the path.
          -key=<path>            Load a file as a private key.
                                 If use with -make-cert, will generate a private key to the path.
+         -cert-pair=<cert,key>  Load another certificate and private key, separate by comma. The certificate is
+                                selected if the server name of the TLS handshake (SNI) matches it's DNS names.
+                                This option could be used more than once.
+         -cert-dir=<path>       Load certificates in a directory, <name>.key and <name>.crt (or <name>.pem,
+                                <name>.cer) are a pair. The certificates are selected by SNI like -cert-pair.
+                                If no certificate matches the server name, the default certificate is used, which is
+                                -cert and -key if provided, otherwise the first of -cert-pair and -cert-dir.
+                                A warning is logged at startup if a host name of -vhost is not in any certificate.
+         -cert-watch=<dur>      Interval of checking if the certificate and the private key are changed.
+                                Changed files are validated and reloaded without a restart,
+                                if the new files are not valid, the old certificate is still in use.
+                                Set to 0 to turn off reloading. Default is 10s.
+                                The certificates and the config could also be reloaded by sending SIGHUP to ran
+                                (not supported on Windows), or by the admin API.
+         -tls-auto              Create an in-memory self-signed certificate at startup for localhost, the hostname
+                                and the bind IPs, -cert and -key are not needed. The SHA-256 fingerprint of the
+                                certificate is shown in the log, so that users could verify it in the browser.
+         -tls-min=<ver>         Minimum TLS version, valid values are 1.0, 1.1, 1.2 and 1.3.
+                                If not provide, the default of Go is used.
+         -tls-max=<ver>         Maximum TLS version. If not provide, the latest version is used.
+         -tls-ciphers=<list>    Cipher suites of TLS 1.0-1.2 in order of preference, separated by comma.
+                                Names are the same as the constants of Go's crypto/tls package,
+                                e.g. TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256. Cipher suites of TLS 1.3 are not
+                                configurable. HTTP/2 needs TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 or
+                                TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256 in the list.
+         -tls-curves=<list>     Elliptic curves used in key exchange in order of preference, separated by comma.
+                                Valid values are X25519, P256, P384 and P521.
+         -tls-session-tickets   Turn on/off TLS session ticket resumption. Default is true.
+         -hsts=<age>            Send the Strict-Transport-Security header with the max age on HTTPS responses.
+                                Example: 31536000, 180d, 1y. With TLS policy redirect, HTTP traffic is redirected
+                                with 301 Moved Permanently instead of 307 Temporary Redirect.
+         -hsts-subdomains       Add includeSubDomains to the Strict-Transport-Security header.
+         -hsts-preload          Add preload to the Strict-Transport-Security header. A max age of at least 1 year,
+                                -hsts-subdomains and TLS policy redirect or only are needed.
+
+         -acme-domain=<domains> Get certificates of the domains from an ACME CA (like Let's Encrypt) and renew them
+                                automatically. Multiple domains should be separated by comma.
+                                -cert and -key cannot be used with this option.
+         -acme-email=<email>    Contact email of the ACME account.
+         -acme-dir=<url>        ACME directory URL. Default is https://acme-v02.api.letsencrypt.org/directory.
+                                Set it to use another CA, e.g. a local test CA like Pebble.
+         -acme-ca-root=<path>   Load a PEM file of CA certificates used to verify the ACME server.
+                                If not provide, the system roots are used.
+         -acme-cache=<path>     Directory to store the ACME account key and certificates.
+                                Default is <user cache directory>/ran/acme.
+         -acme-challenge=<type> ACME challenge type, valid values are http-01 and tls-alpn-01.
+                                http-01 is answered on the HTTP port, so the TLS policy should be redirect or both.
+                                tls-alpn-01 is answered on the HTTPS port.
+                                Default is http-01 if the HTTP port is enabled, otherwise tls-alpn-01.
+         -acme-renew-before=<dur>
+                                Renew certificates before they expire. Default is 720h (30 days).
+
+         -client-ca=<path>      Verify client certificates with the CA certificates in a PEM file (mutual TLS).
+                                Common name of the client certificate is used as the user name in the access log.
+         -client-auth=<mode>    Client certificate mode, valid values are optional and required.
+                                optional: verify the client certificate if it is given, but do not require it.
+                                required: requests without a valid client certificate are rejected.
+                                Default is required.
+         -client-auth-path=<paths>
+                                Only require client certificates under the paths, other paths are open.
+                                Multiple paths should be separated by comma. Example: /admin,/private
 
 Other options:
 
          -make-cert             Generate a self-signed certificate and a private key used in TLS encryption.
                                 You should use -cert and -key to set the output paths.
+                                If use with -ca-cert and -ca-key, the certificate is signed by the local CA.
+         -cert-dns=<names>      DNS names of the generated certificate, separated by comma.
+                                Default is localhost and the hostname.
+         -cert-ip=<ips>         IP addresses of the generated certificate, separated by comma.
+                                Default is the IPs set by -bind-ip, or IPs of all the network interfaces.
+         -cert-days=<n>         Validity of the generated certificate in days.
+                                Default is 3650 for a self-signed certificate, or 825 if signed by the local CA.
+         -cert-key=<type>       Key type of the generated certificate, valid values are rsa, ecdsa and ed25519.
+                                Default is rsa. Note that most browsers do not support ed25519 certificates.
+         -overwrite             Overwrite the existing certificate and private key.
+         -client-cn=<name>      Use with -make-cert to issue a client certificate with the common name,
+                                the certificate is signed by the local CA set by -ca-cert and -ca-key.
+         -ca-cert=<path>        Path of the local CA certificate. If the CA does not exist, a new one is created.
+                                Import the CA certificate to the trust store once, certificates signed by it
+                                are trusted by the browsers.
+         -ca-key=<path>         Path of the local CA private key.
          -showconf              Show config info in the log.
          -debug                 Turn on debug mode.
+    -c,  -config=<path>         Load options from a config file, one option per line in the form of
+                                "<name> <value>", or "<name>" of a bool option. Lines start with # are comments.
+                                Options of the command line override the config file. Example:
+                                    root /var/www
+                                    auth admin:secret
+                                    proxy /api=http://127.0.0.1:9000
     -v,  -version               Show version information.
     -h,  -help                  Show help message.
 
@@ -328,70 +1123,285 @@ Author:
     <https://github.com/m3ng9i>
     <http://mengqi.info>
 `
-fmt.Printf(s)
+fmt.Print(s)
 os.Exit(0)
 }
 
 
-func LoadConfig(versionInfo string) {
+// configError contains the problems found by Setting.check().
+type configError []string
+
+
+func (this configError) Error() string {
+    if len(this) == 1 {
+        return this[0]
+    }
+    var s []string
+    for i, msg := range this {
+        s = append(s, fmt.Sprintf("%d. %s", i + 1, msg))
+    }
+    return strings.Join(s, " ")
+}
+
+
+// readConfigFile returns the options of a config file as command-line arguments.
+// Each line of the file is an option in the form of "<name> <value>", "<name>=<value>" or "<name>" of a bool option,
+// the leading dash of the name could be omitted. Empty lines and lines start with # are ignored.
+func readConfigFile(path string) (args []string, err error) {
+    b, err := os.ReadFile(path)
+    if err != nil {
+        return nil, err
+    }
+
+    for i, line := range strings.Split(string(b), "\n") {
+        line = strings.TrimSpace(line)
+        if line == "" || strings.HasPrefix(line, "#") {
+            continue
+        }
+
+        name, value := line, ""
+        if n := strings.IndexAny(line, " \t="); n >= 0 {
+            name = line[:n]
+            value = strings.TrimSpace(line[n:])
+            value = strings.TrimSpace(strings.TrimPrefix(value, "="))
+        }
+        name = strings.TrimLeft(name, "-")
+        if name == "" {
+            return nil, fmt.Errorf("%s:%d: Option name is empty", path, i + 1)
+        }
+        if name == "c" || name == "config" {
+            return nil, fmt.Errorf("%s:%d: -%s cannot be used in a config file", path, i + 1, name)
+        }
 
+        if value == "" {
+            args = append(args, "-" + name)
+        } else {
+            args = append(args, "-" + name + "=" + value)
+        }
+    }
+    return
+}
+
+
+// LoadConfig parses the command line and the config file, the process exits if the config has an error.
+func LoadConfig(versionInfo string) {
     var err error
-    Config, err = defaultConfig()
+    Config, err = loadConfig(os.Args[1:], versionInfo, false, false)
     if err != nil {
-        fmt.Fprintf(os.Stderr, err.Error())
+        if errmsg, ok := err.(configError); ok && len(errmsg) > 1 {
+            fmt.Fprintln(os.Stderr, "Config error:")
+            for i, msg := range errmsg {
+                fmt.Fprintf(os.Stderr, "%d. %s\n", i + 1, msg)
+            }
+        } else {
+            fmt.Fprintf(os.Stderr, "Config error: %s\n", err)
+        }
         os.Exit(1)
     }
 
+    createLogger()
+}
+
+
+// ReloadConfig parses the command line and the config file again, settings of the site in Config are replaced by
+// the new ones, and the new settings are returned to be used by server.RanServer.SetConfig.
+// Settings of the listeners, TLS, metrics, health checks, maintenance mode, live reload, the admin API and the log level
+// need a restart, they are not changed. If the new config has an error, Config is not changed.
+func ReloadConfig() (server.Config, fs.FS, error) {
+    c, err := loadConfig(os.Args[1:], "", true, false)
+    if err != nil {
+        return server.Config{}, nil, err
+    }
+
+    configMu.Lock()
+    defer configMu.Unlock()
+
+    c.ClientCert    = Config.ClientCert
+    c.HSTS          = Config.HSTS
+    c.Metrics       = Config.Metrics
+    c.Health        = Config.Health
+    c.Maintenance   = Config.Maintenance
+    c.LiveReload    = Config.LiveReload
+    Config.Config   = c.Config
+    Config.Files    = c.Files
+    Config.isArchive = c.isArchive
+
+    return Config.Config, Config.Files, nil
+}
+
+
+// loadConfig parses args and checks the settings. If a config file is set by -c, it is called again with the options
+// of the file followed by args, so the options of the command line have higher priority, fileLoaded is true then.
+// -help, -version and -make-cert print messages and exit the process, they are not used if reload is true.
+func loadConfig(args []string, versionInfo string, reload, fileLoaded bool) (c *Setting, err error) {
+
+    c, err = defaultConfig()
+    if err != nil {
+        return nil, err
+    }
+
+    flags := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+    flags.Usage = usage
+    if reload {
+        flags.Init(os.Args[0], flag.ContinueOnError)
+        flags.SetOutput(io.Discard)
+        flags.Usage = func() {}
+    }
+
     var configPath, bindip, root, path404, authMethod, auth, path401, certPath, keyPath, tlsPolicy string
     var port, tlsPort uint
     var indexName server.Index
-    var version, help, makeCert bool
-
-    flag.StringVar(&configPath, "c",      "", "Path of config file")
-    flag.StringVar(&configPath, "config", "", "Path of config file")
-
-    if configPath != "" {
-        // TODO: load config file
-    }
-
-    flag.StringVar(&bindip,             "b",                "",      "IP addresses binded to ran server")
-    flag.StringVar(&bindip,             "bind-ip",          "",      "IP addresses binded to ran server")
-    flag.UintVar(  &port,               "p",                0,       "HTTP port")
-    flag.UintVar(  &port,               "port",             0,       "HTTP port")
-    flag.StringVar(&root,               "r",                "",      "Root path of the website")
-    flag.StringVar(&root,               "root",             "",      "Root path of the website")
-    flag.StringVar(&path404,            "404",              "",      "Path of a custom 404 file")
-    flag.StringVar(&path401,            "401",              "",      "Path of a custom 401 file")
-    flag.StringVar(&authMethod,         "am",               "basic", "authentication method")
-    flag.StringVar(&authMethod,         "auth-method",      "basic", "authentication method")
-    flag.StringVar(&auth,               "a",                "",      "Username and password of auth, separate by colon")
-    flag.StringVar(&auth,               "auth",             "",      "Username and password of auth, separate by colon")
-    flag.Var(      &indexName,          "i",                         "File name of index, separate by colon")
-    flag.Var(      &indexName,          "index",                     "File name of index, separate by colon")
-    flag.BoolVar(  &Config.ListDir,     "l",                false,   "Show file list of a directory")
-    flag.BoolVar(  &Config.ListDir,     "listdir",          false,   "Show file list of a directory")
-    flag.BoolVar(  &Config.ServeAll,    "sa",               false,   "Serve all paths even if the path is start with dot")
-    flag.BoolVar(  &Config.ServeAll,    "serve-all",        false,   "Serve all paths even if the path is start with dot")
-    flag.BoolVar(  &Config.Gzip,        "g",                true,    "Turn on/off gzip compression")
-    flag.BoolVar(  &Config.Gzip,        "gzip",             true,    "Turn on/off gzip compression")
-    flag.BoolVar(  &Config.NoCache,     "nc",               false,   "If send no-cache header")
-    flag.BoolVar(  &Config.NoCache,     "no-cache",         false,   "If send no-cache header")
-    flag.BoolVar(  &Config.CORS,        "cors",             false,   "If send CORS headers")
-    flag.BoolVar(  &Config.ShowConf,    "showconf",         false,   "If show config info in the log")
-    flag.BoolVar(  &Config.Debug,       "debug",            false,   "Turn on debug mode")
-    flag.BoolVar(  &version,            "v",                false,   "Show version information")
-    flag.BoolVar(  &version,            "version",          false,   "Show version information")
-    flag.BoolVar(  &help,               "h",                false,   "Show help message")
-    flag.BoolVar(  &help,               "help",             false,   "Show help message")
-    flag.BoolVar(  &makeCert,           "make-cert",        false,   "Generate a self-signed certificate and a private key")
-    flag.StringVar(&certPath,           "cert",             "",      "Path of certificate")
-    flag.StringVar(&keyPath,            "key",              "",      "Path of private key")
-    flag.UintVar(  &tlsPort,            "tls-port",         0,       "HTTPS port")
-    flag.StringVar(&tlsPolicy,          "tls-policy",       "",      "TLS policy")
-
-    flag.Usage = usage
-
-    flag.Parse()
+    var rateLimit float64
+    var rateBurst uint
+    var bandwidth, globalBandwidth server.ByteSize
+    var ratePaths server.PathRateLimits
+    var proxyRoutes server.ProxyRoutes
+    var certPairs server.CertPairs
+    var certDir string
+    var acmeDomains, acmeEmail, acmeDir, acmeCARoot, acmeCache, acmeChallenge string
+    var acmeRenewBefore, certWatch, bindWatch time.Duration
+    var clientCA, clientAuth, clientAuthPaths, clientCN, caCert, caKey string
+    var tlsMin, tlsMax, tlsCiphers, tlsCurves string
+    var tlsSessionTickets, hstsSubdomains, hstsPreload bool
+    var hsts server.MaxAge
+    var proxyStripPrefix, proxyPreserveHost bool
+    var proxyTimeout time.Duration
+    proxyHeaders := server.Header{}
+    var version, help, makeCert, overwrite, tlsAuto bool
+    var certDNS, certIP, certKey string
+    var certDays int
+    var listen, listenMode, listenOwner string
+    var metricsPath, metricsListen string
+    var healthPrefix string
+    var healthLog bool
+    var adminListen, adminAuth string
+    var path503, maintenanceFile string
+    var maintenanceOn bool
+    var maintenanceRetryAfter time.Duration
+    var maintenanceAllow server.IPNets
+    var liveReload bool
+    var liveReloadIgnore server.Patterns
+
+    flags.StringVar(&configPath, "c",      "", "Path of config file")
+    flags.StringVar(&configPath, "config", "", "Path of config file")
+
+    flags.StringVar(&bindip,             "b",                "",      "IP addresses binded to ran server")
+    flags.StringVar(&bindip,             "bind-ip",          "",      "IP addresses binded to ran server")
+    flags.DurationVar(&bindWatch,        "bind-watch",       0,       "Interval of checking if addresses of the network interfaces are changed")
+    flags.UintVar(  &port,               "p",                0,       "HTTP port")
+    flags.UintVar(  &port,               "port",             0,       "HTTP port")
+    flags.StringVar(&listen,             "listen",           "",      "Unix domain sockets to listen on, separate by comma")
+    flags.StringVar(&listenMode,         "listen-mode",      "",      "File mode of the Unix domain sockets")
+    flags.StringVar(&listenOwner,        "listen-owner",     "",      "Owner of the Unix domain sockets")
+    flags.StringVar(&root,               "r",                "",      "Root path of the website")
+    flags.StringVar(&root,               "root",             "",      "Root path of the website")
+    flags.StringVar(&path404,            "404",              "",      "Path of a custom 404 file")
+    flags.StringVar(&path401,            "401",              "",      "Path of a custom 401 file")
+    flags.StringVar(&path503,            "503",              "",      "Path of a custom 503 file shown in maintenance mode")
+    flags.StringVar(&authMethod,         "am",               "basic", "authentication method")
+    flags.StringVar(&authMethod,         "auth-method",      "basic", "authentication method")
+    flags.StringVar(&auth,               "a",                "",      "Username and password of auth, separate by colon")
+    flags.StringVar(&auth,               "auth",             "",      "Username and password of auth, separate by colon")
+    flags.Var(      &indexName,          "i",                         "File name of index, separate by colon")
+    flags.Var(      &indexName,          "index",                     "File name of index, separate by colon")
+    flags.BoolVar(  &c.ListDir,     "l",                false,   "Show file list of a directory")
+    flags.BoolVar(  &c.ListDir,     "listdir",          false,   "Show file list of a directory")
+    flags.BoolVar(  &c.ServeAll,    "sa",               false,   "Serve all paths even if the path is start with dot")
+    flags.BoolVar(  &c.ServeAll,    "serve-all",        false,   "Serve all paths even if the path is start with dot")
+    flags.BoolVar(  &c.Gzip,        "g",                true,    "Turn on/off gzip compression")
+    flags.BoolVar(  &c.Gzip,        "gzip",             true,    "Turn on/off gzip compression")
+    flags.BoolVar(  &c.NoCache,     "nc",               false,   "If send no-cache header")
+    flags.BoolVar(  &c.NoCache,     "no-cache",         false,   "If send no-cache header")
+    flags.Var(      &c.CachePolicy, "cache",                     "Cache-Control rule")
+    flags.BoolVar(  &c.CacheHashed, "cache-hashed",     false,   "Mark files with content hash in the name as immutable")
+    flags.BoolVar(  &c.CORS,        "cors",             false,   "If send CORS headers")
+    flags.BoolVar(  &c.ShowConf,    "showconf",         false,   "If show config info in the log")
+    flags.BoolVar(  &c.Debug,       "debug",            false,   "Turn on debug mode")
+    flags.BoolVar(  &version,            "v",                false,   "Show version information")
+    flags.BoolVar(  &version,            "version",          false,   "Show version information")
+    flags.BoolVar(  &help,               "h",                false,   "Show help message")
+    flags.BoolVar(  &help,               "help",             false,   "Show help message")
+    flags.BoolVar(  &makeCert,           "make-cert",        false,   "Generate a self-signed certificate and a private key")
+    flags.StringVar(&certPath,           "cert",             "",      "Path of certificate")
+    flags.StringVar(&keyPath,            "key",              "",      "Path of private key")
+    flags.Var(      &certPairs,          "cert-pair",                 "Certificate and private key selected by SNI, separate by comma")
+    flags.StringVar(&certDir,            "cert-dir",         "",      "Directory of certificates selected by SNI")
+    flags.DurationVar(&certWatch,        "cert-watch",       10 * time.Second, "Interval of checking if the certificate files are changed")
+    flags.BoolVar(  &tlsAuto,            "tls-auto",         false,   "Create an in-memory self-signed certificate at startup")
+    flags.StringVar(&tlsMin,             "tls-min",          "",      "Minimum TLS version")
+    flags.StringVar(&tlsMax,             "tls-max",          "",      "Maximum TLS version")
+    flags.StringVar(&tlsCiphers,         "tls-ciphers",      "",      "Cipher suites, separate by comma")
+    flags.StringVar(&tlsCurves,          "tls-curves",       "",      "Elliptic curves, separate by comma")
+    flags.BoolVar(  &tlsSessionTickets,  "tls-session-tickets", true, "Turn on/off TLS session tickets")
+    flags.Var(      &hsts,               "hsts",                      "Max age of the Strict-Transport-Security header")
+    flags.BoolVar(  &hstsSubdomains,     "hsts-subdomains",  false,   "Add includeSubDomains to the Strict-Transport-Security header")
+    flags.BoolVar(  &hstsPreload,        "hsts-preload",     false,   "Add preload to the Strict-Transport-Security header")
+    flags.StringVar(&clientCA,           "client-ca",        "",      "CA certificates used to verify client certificates")
+    flags.StringVar(&clientAuth,         "client-auth",      string(DefaultClientAuthMode), "Client certificate mode: optional or required")
+    flags.StringVar(&clientAuthPaths,    "client-auth-path", "",      "Paths which require a client certificate, separate by comma")
+    flags.StringVar(&certDNS,            "cert-dns",         "",      "DNS names of the certificate generated by -make-cert")
+    flags.StringVar(&certIP,             "cert-ip",          "",      "IP addresses of the certificate generated by -make-cert")
+    flags.IntVar(   &certDays,           "cert-days",        0,       "Validity of the certificate generated by -make-cert in days")
+    flags.StringVar(&certKey,            "cert-key",         string(KeyRSA), "Key type of the certificate generated by -make-cert")
+    flags.BoolVar(  &overwrite,          "overwrite",        false,   "Overwrite the existing certificate and private key")
+    flags.StringVar(&clientCN,           "client-cn",        "",      "Common name of the client certificate generated by -make-cert")
+    flags.StringVar(&caCert,             "ca-cert",          "",      "Path of the local CA certificate")
+    flags.StringVar(&caKey,              "ca-key",           "",      "Path of the local CA private key")
+    flags.Float64Var(&rateLimit,         "rate-limit",       0,       "Requests per second per client IP")
+    flags.UintVar(  &rateBurst,          "rate-burst",       0,       "Max number of requests a client could send at once")
+    flags.Var(      &bandwidth,          "bandwidth",                 "Bytes per second per client IP")
+    flags.Var(      &globalBandwidth,    "global-bandwidth",          "Bytes per second of all clients")
+    flags.Var(      &ratePaths,          "rate-path",                 "Override rate limit by path prefix")
+    flags.UintVar(  &c.MaxConns,    "max-conns",        0,       "Max number of simultaneous connections")
+    flags.UintVar(  &c.MaxConnsPerIP, "max-conns-per-ip", 0,     "Max number of simultaneous connections per client IP")
+    flags.BoolVar(  &c.HTTP2.H2C,   "h2c",              false,   "Accept HTTP/2 without TLS on HTTP listeners")
+    flags.BoolVar(  &c.HTTP3,       "http3",            false,   "Serve HTTP/3 on the UDP port of HTTPS")
+    flags.UintVar(  &c.HTTP2.MaxConcurrentStreams, "http2-max-streams", 0, "Max number of concurrent HTTP/2 streams per connection")
+    flags.UintVar(  &c.HTTP2.MaxReadFrameSize, "http2-max-frame-size", 0, "Max size of HTTP/2 frames")
+    flags.StringVar(&metricsPath,        "metrics",          "",      "Serve Prometheus metrics on the path")
+    flags.StringVar(&metricsListen,      "metrics-listen",   "",      "Address of a separate listener of metrics")
+    flags.StringVar(&healthPrefix,       "health",           "",      "Path prefix of the health endpoints")
+    flags.BoolVar(  &healthLog,          "health-log",       true,    "Write requests of the health endpoints to the access log")
+    flags.BoolVar(  &maintenanceOn,      "maintenance",      false,   "Turn on maintenance mode at startup")
+    flags.StringVar(&maintenanceFile,    "maintenance-file", "",      "Maintenance mode is on while the file exists")
+    flags.DurationVar(&maintenanceRetryAfter, "maintenance-retry-after", 5 * time.Minute, "Value of the Retry-After header in maintenance mode")
+    flags.Var(      &maintenanceAllow,   "maintenance-allow",         "IP addresses or networks which bypass maintenance mode")
+    flags.BoolVar(  &liveReload,         "live-reload",      false,   "Reload pages in browsers when files are changed")
+    flags.Var(      &liveReloadIgnore,   "live-reload-ignore",        "Paths which are not watched by live reload")
+    flags.StringVar(&adminListen,        "admin",            "",      "Address of the admin API listener")
+    flags.StringVar(&adminAuth,          "admin-auth",       "",      "Username and password of the admin API, separate by colon")
+    flags.Var(      &c.VirtualHosts, "vhost",                    "Serve a host name from a separate root")
+    flags.Var(      &c.Mounts,      "mount",                     "Serve a separate root under a URL prefix")
+    flags.Var(      &proxyRoutes,        "proxy",                     "Forward requests under a path prefix to a backend")
+    flags.BoolVar(  &proxyStripPrefix,   "proxy-strip-prefix", false, "Remove the prefix from the path before forwarding")
+    flags.BoolVar(  &proxyPreserveHost,  "proxy-preserve-host", false, "Send the Host header of the client to the backend")
+    flags.DurationVar(&proxyTimeout,     "proxy-timeout",    30 * time.Second, "Timeout of proxy")
+    flags.Var(      proxyHeaders,        "proxy-header",              "Set a header to the requests sent to the backend")
+    flags.UintVar(  &tlsPort,            "tls-port",         0,       "HTTPS port")
+    flags.StringVar(&tlsPolicy,          "tls-policy",       "",      "TLS policy")
+    flags.StringVar(&acmeDomains,        "acme-domain",      "",      "Get certificates of the domains from an ACME CA")
+    flags.StringVar(&acmeEmail,          "acme-email",       "",      "Contact email of the ACME account")
+    flags.StringVar(&acmeDir,            "acme-dir",         DefaultACMEDirectory, "ACME directory URL")
+    flags.StringVar(&acmeCARoot,         "acme-ca-root",     "",      "CA certificates used to verify the ACME server")
+    flags.StringVar(&acmeCache,          "acme-cache",       defaultACMECacheDir(), "Directory to store ACME account key and certificates")
+    flags.StringVar(&acmeChallenge,      "acme-challenge",   "",      "ACME challenge type")
+    flags.DurationVar(&acmeRenewBefore,  "acme-renew-before", DefaultACMERenewBefore, "Renew certificates before they expire")
+
+    if err = flags.Parse(args); err != nil {
+        return nil, err
+    }
+
+    if configPath != "" && !fileLoaded {
+        fileArgs, err := readConfigFile(configPath)
+        if err != nil {
+            return nil, err
+        }
+        return loadConfig(append(fileArgs, args...), versionInfo, reload, true)
+    }
+
+    if reload {
+        help, version, makeCert = false, false, false
+    }
 
     if help {
         usage()
@@ -403,93 +1413,316 @@ func LoadConfig(versionInfo string) {
     }
 
     if makeCert {
-        err = makeCertFiles(certPath, keyPath, false)
-        if err != nil {
-            fmt.Fprintf(os.Stderr, "Error: %s\n", err)
-            os.Exit(1)
+        option := &CertOption {
+            Cert:       certPath,
+            Key:        keyPath,
+            ClientCN:   clientCN,
+            Days:       certDays,
+            KeyType:    KeyType(strings.ToLower(certKey)),
+            CACert:     caCert,
+            CAKey:      caKey,
+            Overwrite:  overwrite,
+        }
+
+        exitOnError := func(err error) {
+            if err != nil {
+                fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+                os.Exit(1)
+            }
+        }
+
+        // subject alternative names of a server certificate, default to localhost, the hostname and the bind IPs
+        if clientCN == "" {
+            ips, err := getIPs(bindip)
+            exitOnError(err)
+            option.DNSNames, option.IPs = defaultCertHosts(ips)
+
+            if certDNS != "" {
+                option.DNSNames = nil
+                for _, name := range strings.Split(certDNS, ",") {
+                    if name = strings.TrimSpace(name); name != "" {
+                        option.DNSNames = append(option.DNSNames, name)
+                    }
+                }
+            }
+            if certIP != "" {
+                option.IPs, err = parseCertIPs(certIP)
+                exitOnError(err)
+            }
+        }
+
+        caCreated, err := makeCertFiles(option)
+        exitOnError(err)
+
+        if caCreated {
+            fmt.Println("CA certificate and private key are created")
+        }
+        if clientCN != "" {
+            fmt.Println("Client certificate and private key are created")
+        } else {
+            fmt.Println("Certificate and private key are created")
+            fmt.Printf("DNS names: %s\n", strings.Join(option.DNSNames, ", "))
+            var ips []string
+            for _, ip := range option.IPs {
+                ips = append(ips, ip.String())
+            }
+            fmt.Printf("IP addresses: %s\n", strings.Join(ips, ", "))
         }
-        fmt.Println("Certificate and private key are created")
         os.Exit(0)
     }
 
     // load TLS config
-    if certPath != "" || keyPath != "" || tlsPort > 0 || tlsPolicy != "" {
-        if Config.TLS == nil {
-            Config.TLS = new(TLSOption)
+    if certPath != "" || keyPath != "" || len(certPairs) > 0 || certDir != "" || tlsPort > 0 || tlsPolicy != "" ||
+        acmeDomains != "" || tlsAuto {
+        if c.TLS == nil {
+            c.TLS = new(TLSOption)
+        }
+        c.TLS.PublicKey    = certPath
+        c.TLS.PrivateKey   = keyPath
+        c.TLS.Certificates = certPairs
+        c.TLS.CertDir      = certDir
+        c.TLS.Port         = tlsPort
+        c.TLS.Policy       = TLSPolicy(tlsPolicy)
+        c.TLS.CertWatch    = certWatch
+        c.TLS.Auto         = tlsAuto
+
+    }
+
+    // set default value for c.TLS
+    if c.TLS != nil {
+        if c.TLS.Port == 0 {
+            c.TLS.Port = DefaultTLSPort
+        }
+        if c.TLS.Policy == "" {
+            c.TLS.Policy = DefaultTLSPolicy
         }
-        Config.TLS.PublicKey    = certPath
-        Config.TLS.PrivateKey   = keyPath
-        Config.TLS.Port         = tlsPort
-        Config.TLS.Policy       = TLSPolicy(tlsPolicy)
+    }
 
+    // load ACME config
+    if acmeDomains != "" {
+        acme := new(ACMEOption)
+        for _, domain := range strings.Split(acmeDomains, ",") {
+            domain = strings.TrimSpace(domain)
+            if domain != "" {
+                acme.Domains = append(acme.Domains, domain)
+            }
+        }
+        acme.Email          = acmeEmail
+        acme.DirectoryURL   = acmeDir
+        acme.CARoot         = acmeCARoot
+        acme.CacheDir       = acmeCache
+        acme.RenewBefore    = acmeRenewBefore
+        acme.Challenge      = ACMEChallenge(strings.ToLower(acmeChallenge))
+
+        // use http-01 challenge if the HTTP port is available
+        if acme.Challenge == "" {
+            if c.TLS.Policy == TLSOnly {
+                acme.Challenge = ACMETLSALPN01
+            } else {
+                acme.Challenge = ACMEHTTP01
+            }
+        }
+
+        c.TLS.ACME = acme
     }
 
-    // set default value for Config.TLS
-    if Config.TLS != nil {
-        if Config.TLS.Port == 0 {
-            Config.TLS.Port = DefaultTLSPort
+    // load TLS protocol config
+    if tlsMin != "" || tlsMax != "" || tlsCiphers != "" || tlsCurves != "" || !tlsSessionTickets {
+        if c.TLS == nil {
+            return nil, errors.New("TLS options need TLS, use them with -cert and -key, -tls-auto or -acme-domain")
+        }
+
+        if c.TLS.MinVersion, err = parseTLSVersion(tlsMin); err != nil {
+            return nil, err
         }
-        if Config.TLS.Policy == "" {
-            Config.TLS.Policy = DefaultTLSPolicy
+        if c.TLS.MaxVersion, err = parseTLSVersion(tlsMax); err != nil {
+            return nil, err
+        }
+        if c.TLS.CipherSuites, err = parseCipherSuites(tlsCiphers); err != nil {
+            return nil, err
+        }
+        if c.TLS.Curves, err = parseCurves(tlsCurves); err != nil {
+            return nil, err
+        }
+        c.TLS.NoSessionTickets = !tlsSessionTickets
+    }
+
+    if hsts > 0 {
+        c.HSTS = &server.HSTS {
+            MaxAge:             hsts,
+            IncludeSubDomains:  hstsSubdomains,
+            Preload:            hstsPreload,
         }
     }
 
-    Config.IP, err = getIPs(bindip)
+    // load client certificate authentication config
+    if clientCA != "" {
+        if c.TLS == nil {
+            return nil, errors.New("-client-ca needs TLS, use it with -cert and -key, -tls-auto or -acme-domain")
+        }
+
+        option := &ClientAuthOption {
+            CA:     clientCA,
+            Mode:   ClientAuthMode(strings.ToLower(clientAuth)),
+        }
+        for _, p := range strings.Split(clientAuthPaths, ",") {
+            p = strings.TrimSpace(p)
+            if p != "" {
+                option.Paths = append(option.Paths, p)
+            }
+        }
+        c.TLS.ClientAuth = option
+
+        // the handler checks client certificates if they are not required in the TLS handshake,
+        // it also rejects requests to the HTTP port when the TLS policy is "both".
+        if option.Mode == ClientAuthRequired {
+            c.ClientCert = &server.ClientCert{Paths: option.Paths}
+        }
+    }
+
+    c.IP, err = getIPs(bindip)
+    if err == errNoIP && bindWatch > 0 {
+        // the network interfaces may get addresses later, e.g. from DHCP
+        err = nil
+    }
     if err != nil {
-        fmt.Fprintf(os.Stderr, "Error: %s\n", err)
-        os.Exit(1)
+        return nil, err
     }
+    c.bindIP = bindip
+    c.BindWatch = bindWatch
 
     if port > 0 {
-        Config.Port = port
+        c.Port = port
+    }
+
+    if listen != "" {
+        c.Unix = &UnixSocketOption{Owner: listenOwner}
+        for _, item := range strings.Split(listen, ",") {
+            item = strings.TrimSpace(item)
+            if item == "" {
+                continue
+            }
+            path, err := parseListenAddr(item)
+            if err != nil {
+                return nil, err
+            }
+            c.Unix.Paths = append(c.Unix.Paths, path)
+        }
+        if listenMode != "" {
+            c.Unix.Mode, err = parseFileMode(listenMode)
+            if err != nil {
+                return nil, err
+            }
+        }
+    } else if listenMode != "" || listenOwner != "" {
+        return nil, errors.New("-listen-mode and -listen-owner need -listen")
     }
 
     if root != "" {
-        Config.Root = root
+        c.Root = root
     }
 
     if path404 != "" {
-        Config.errorFile404 = &path404
+        c.errorFile404 = &path404
     }
 
     if len(indexName) > 0 {
-        Config.IndexName = indexName
+        c.IndexName = indexName
     }
 
     if auth != "" {
-        if Config.Auth == nil {
-            Config.Auth = new(server.Auth)
+        if c.Auth == nil {
+            c.Auth = new(server.Auth)
         }
         authPair := strings.SplitN(auth, ":", 2)
         if len(authPair) != 2 {
-            fmt.Fprintln(os.Stderr, "Config error: format of auth not correct")
-            os.Exit(1)
+            return nil, errors.New("format of auth not correct")
         }
-        Config.Auth.Username = authPair[0]
-        Config.Auth.Password = authPair[1]
-        Config.Auth.Method = server.AuthMethod(strings.ToLower(authMethod))
+        c.Auth.Username = authPair[0]
+        c.Auth.Password = authPair[1]
+        c.Auth.Method = server.AuthMethod(strings.ToLower(authMethod))
 
         if path401 != "" {
-            Config.errorFile401 = &path401
+            c.errorFile401 = &path401
         }
     }
 
-    // check Config
-    errmsg := Config.check()
-    if len(errmsg) == 1 {
-        fmt.Fprintf(os.Stderr, "Config error: %s\n", errmsg[0])
-        os.Exit(1)
-    } else if len(errmsg) > 1 {
-        fmt.Fprintln(os.Stderr, "Config error:")
-        for i, msg := range errmsg {
-            fmt.Fprintf(os.Stderr, "%d. %s\n", i + 1, msg)
+    if rateLimit != 0 || bandwidth > 0 || globalBandwidth > 0 || len(ratePaths) > 0 {
+        c.RateLimit = &server.RateLimit {
+            Requests:           rateLimit,
+            Burst:              int(rateBurst),
+            Bandwidth:          bandwidth,
+            GlobalBandwidth:    globalBandwidth,
+            Paths:              ratePaths,
         }
-        os.Exit(1)
     }
 
-    createLogger(Config.Debug)
+    if len(proxyRoutes) > 0 {
+        c.Proxy = &server.Proxy {
+            Routes:         proxyRoutes,
+            StripPrefix:    proxyStripPrefix,
+            PreserveHost:   proxyPreserveHost,
+            Timeout:        proxyTimeout,
+            Headers:        proxyHeaders,
+        }
+    }
 
-    return
+    if metricsPath != "" || metricsListen != "" {
+        if metricsPath == "" {
+            metricsPath = "/metrics"
+        }
+        c.Metrics = &server.MetricsOption {
+            Path:       metricsPath,
+            Separate:   metricsListen != "",
+        }
+        c.MetricsListen = metricsListen
+    }
+
+    if healthPrefix != "" {
+        c.Health = &server.Health {
+            Prefix: strings.TrimSuffix(healthPrefix, "/"),
+            NoLog:  !healthLog,
+        }
+    }
+
+    c.Maintenance = &server.MaintenanceOption {
+        On:         maintenanceOn,
+        RetryAfter: maintenanceRetryAfter,
+        Allow:      maintenanceAllow,
+    }
+    if path503 != "" {
+        c.errorFile503 = &path503
+    }
+    if maintenanceFile != "" {
+        c.maintenanceFile = &maintenanceFile
+    }
+
+    if liveReload {
+        c.LiveReload = &server.LiveReloadOption {
+            Ignore: liveReloadIgnore,
+        }
+    }
+
+    if adminListen != "" {
+        c.AdminListen = adminListen
+        if adminAuth != "" {
+            authPair := strings.SplitN(adminAuth, ":", 2)
+            if len(authPair) != 2 {
+                return nil, errors.New("format of admin auth not correct")
+            }
+            c.AdminAuth = &server.Auth {
+                Username:   authPair[0],
+                Password:   authPair[1],
+                Method:     server.BasicMethod,
+            }
+        }
+    }
+
+    if errmsg := c.check(); len(errmsg) > 0 {
+        return nil, configError(errmsg)
+    }
+
+    return c, nil
 }
 
 
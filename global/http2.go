@@ -0,0 +1,42 @@
+package global
+
+import "net/http"
+
+
+// HTTP2Option contains options of HTTP/2.
+type HTTP2Option struct {
+    H2C                     bool    // If true, HTTP listeners accept HTTP/2 without TLS (h2c).
+    MaxConcurrentStreams    uint    // Max number of concurrent streams per connection. 0 means the default of Go (250).
+    MaxReadFrameSize        uint    // Max size of a frame could be read, 16K to 16M. 0 means the default of Go (1M).
+}
+
+
+// http2 frame size limits, defined in RFC 7540
+const (
+    minHTTP2FrameSize uint = 1 << 14
+    maxHTTP2FrameSize uint = 1 << 24 - 1
+)
+
+
+// config returns the HTTP/2 settings of net/http, nil means the default settings.
+func (this *HTTP2Option) config() *http.HTTP2Config {
+    if this.MaxConcurrentStreams == 0 && this.MaxReadFrameSize == 0 {
+        return nil
+    }
+    return &http.HTTP2Config {
+        MaxConcurrentStreams:   int(this.MaxConcurrentStreams),
+        MaxReadFrameSize:       int(this.MaxReadFrameSize),
+    }
+}
+
+
+// ConfigureServer applies the HTTP/2 settings to a server. A server of HTTPS serves HTTP/2 by the built-in support
+// of net/http, a server of HTTP serves HTTP/2 without TLS (h2c with prior knowledge) only if H2C is true.
+func (this *HTTP2Option) ConfigureServer(s *http.Server, useTLS bool) {
+    s.HTTP2 = this.config()
+    if !useTLS && this.H2C {
+        s.Protocols = new(http.Protocols)
+        s.Protocols.SetHTTP1(true)
+        s.Protocols.SetUnencryptedHTTP2(true)
+    }
+}
@@ -1,12 +1,17 @@
 package global
 
 import (
+    "errors"
     "net"
     "strings"
     "fmt"
 )
 
 
+// errNoIP is returned by getIPs if no IP address is found, e.g. the network interfaces have no address yet.
+var errNoIP = errors.New("No IP address provided")
+
+
 // Get network interface index by it's IP.
 // Usage example: getInterfaceIndexByIP(net.ParseIP("fe80::aaaa:bbbb:cccc:dddd"))
 func getInterfaceIndexByIP(addr net.IP) (index int, e error) {
@@ -52,7 +57,55 @@ func isIPv6LinkLocalAddress(addr net.IP) bool {
 }
 
 
-// get all IPs from command-line option -bind-ip
+// get IP addresses of a network interface, IPv6 addresses are enclosed in brackets,
+// and IPv6 link local addresses have a scope (interface index).
+func getInterfaceIPs(name string) (ips []string, err error) {
+    iface, err := net.InterfaceByName(name)
+    if err != nil {
+        return
+    }
+
+    // an interface which is down has no usable address
+    if iface.Flags & net.FlagUp == 0 {
+        return
+    }
+
+    addrs, err := iface.Addrs()
+    if err != nil {
+        return
+    }
+
+    for _, a := range addrs {
+        ipnet, ok := a.(*net.IPNet)
+        if !ok {
+            continue
+        }
+        if ipnet.IP.To4() != nil {
+            ips = append(ips, ipnet.IP.String())
+        } else if isIPv6LinkLocalAddress(ipnet.IP) {
+            ips = append(ips, fmt.Sprintf("[%s%%%d]", ipnet.IP.String(), iface.Index))
+        } else {
+            ips = append(ips, fmt.Sprintf("[%s]", ipnet.IP.String()))
+        }
+    }
+
+    return
+}
+
+
+// check if the value of -bind-ip contains names of network interfaces
+func hasInterfaceName(bindIP string) bool {
+    for _, item := range strings.Split(bindIP, ",") {
+        item = strings.TrimSpace(item)
+        if item != "" && net.ParseIP(item) == nil {
+            return true
+        }
+    }
+    return false
+}
+
+
+// get all IPs from command-line option -bind-ip, the option could contain IP addresses and names of network interfaces.
 func getIPs(bindIP string) (ips []string, err error) {
     anyIP := []string{"0.0.0.0"}
 
@@ -91,12 +144,20 @@ func getIPs(bindIP string) (ips []string, err error) {
                 }
             }
         } else {
-            invalidIPs = append(invalidIPs, item)
+            // name of a network interface, use it's current addresses
+            addrs, e := getInterfaceIPs(item)
+            if e != nil {
+                invalidIPs = append(invalidIPs, item)
+                continue
+            }
+            for _, a := range addrs {
+                allIPs[a] = nil
+            }
         }
     }
 
     if len(invalidIPs) > 0 {
-        err = fmt.Errorf("Invalid IP: %s", strings.Join(invalidIPs, ", "))
+        err = fmt.Errorf("Invalid IP or network interface: %s", strings.Join(invalidIPs, ", "))
         return
     }
 
@@ -115,10 +176,20 @@ func getIPs(bindIP string) (ips []string, err error) {
     }
 
     if len(ips) == 0 {
-        err = fmt.Errorf("No IP address provided")
+        err = errNoIP
         return
     }
 
     return
 }
 
+
+
+// CurrentIPs resolves -bind-ip again and returns the current addresses of the network interfaces.
+func (this *Setting) CurrentIPs() (ips []string, err error) {
+    ips, err = getIPs(this.bindIP)
+    if err == errNoIP {
+        err = nil
+    }
+    return
+}
@@ -0,0 +1,245 @@
+package global
+
+import "fmt"
+import "net"
+import "os"
+import "os/user"
+import "strconv"
+import "strings"
+import "time"
+
+
+// UnixSocketOption contains options of the Unix domain sockets ran listens on.
+type UnixSocketOption struct {
+    Paths   []string        // Paths of the sockets.
+    Mode    os.FileMode     // File mode of the sockets. 0 means the mode is decided by umask.
+    Owner   string          // Owner of the sockets in the form of <user>[:<group>], could be names or ids.
+                            // Empty means not to change.
+}
+
+
+// parse a -listen value in the form of unix:<path>
+func parseListenAddr(value string) (path string, err error) {
+    if !strings.HasPrefix(value, "unix:") || strings.TrimPrefix(value, "unix:") == "" {
+        err = fmt.Errorf(`Listen address should be in the form of unix:<path>, got '%s'`, value)
+        return
+    }
+    path = strings.TrimPrefix(value, "unix:")
+    return
+}
+
+
+// parse a file mode in octal, like 0660
+func parseFileMode(value string) (mode os.FileMode, err error) {
+    m, err := strconv.ParseUint(value, 8, 32)
+    if err != nil || m > 0777 {
+        err = fmt.Errorf("Invalid file mode: %s", value)
+        return
+    }
+    mode = os.FileMode(m)
+    return
+}
+
+
+// lookupOwner converts <user>[:<group>] to uid and gid, -1 means not to change.
+func lookupOwner(owner string) (uid, gid int, err error) {
+    uid, gid = -1, -1
+
+    pair := strings.SplitN(owner, ":", 2)
+
+    if pair[0] != "" {
+        uid, err = strconv.Atoi(pair[0])
+        if err != nil {
+            var u *user.User
+            u, err = user.Lookup(pair[0])
+            if err != nil {
+                return
+            }
+            uid, err = strconv.Atoi(u.Uid)
+            if err != nil {
+                return
+            }
+        }
+    }
+
+    if len(pair) == 2 && pair[1] != "" {
+        gid, err = strconv.Atoi(pair[1])
+        if err != nil {
+            var g *user.Group
+            g, err = user.LookupGroup(pair[1])
+            if err != nil {
+                return
+            }
+            gid, err = strconv.Atoi(g.Gid)
+            if err != nil {
+                return
+            }
+        }
+    }
+
+    return
+}
+
+
+// removeStaleSocket removes a socket file left by a process which is not running.
+// If another process is listening on the socket, an error is returned.
+func removeStaleSocket(path string) error {
+    info, err := os.Stat(path)
+    if os.IsNotExist(err) {
+        return nil
+    } else if err != nil {
+        return err
+    }
+
+    if info.Mode() & os.ModeSocket == 0 {
+        return fmt.Errorf("'%s' is exist and is not a socket", path)
+    }
+
+    conn, err := net.DialTimeout("unix", path, time.Second)
+    if err == nil {
+        conn.Close()
+        return fmt.Errorf("'%s' is in use by another process", path)
+    }
+
+    return os.Remove(path)
+}
+
+
+// ListenUnix listens on a Unix domain socket and sets it's mode and owner.
+// The socket file is removed when the listener is closed.
+func ListenUnix(path string, option *UnixSocketOption) (net.Listener, error) {
+    if err := removeStaleSocket(path); err != nil {
+        return nil, err
+    }
+
+    l, err := net.Listen("unix", path)
+    if err != nil {
+        return nil, err
+    }
+
+    if option.Mode != 0 {
+        if err = os.Chmod(path, option.Mode); err != nil {
+            l.Close()
+            return nil, err
+        }
+    }
+
+    if option.Owner != "" {
+        uid, gid, err := lookupOwner(option.Owner)
+        if err == nil {
+            err = os.Chown(path, uid, gid)
+        }
+        if err != nil {
+            l.Close()
+            return nil, err
+        }
+    }
+
+    return l, nil
+}
+
+
+// the first file descriptor passed by systemd, after stdin, stdout and stderr
+const systemdFirstFD = 3
+
+
+// SystemdSockets contains the listeners passed by systemd socket activation.
+// Sockets named "https" (FileDescriptorName=https in the .socket unit) are served with TLS, others are served with HTTP.
+type SystemdSockets struct {
+    HTTP    []net.Listener
+    HTTPS   []net.Listener
+}
+
+
+// SystemdListeners returns the listeners passed by systemd socket activation,
+// if ran is not started by socket activation, nil is returned.
+// The environment variables of socket activation are removed, so they are not passed to child processes.
+func SystemdListeners() (sockets *SystemdSockets, err error) {
+    pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+    if err != nil || pid != os.Getpid() {
+        return nil, nil
+    }
+
+    n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+    if err != nil || n <= 0 {
+        return nil, fmt.Errorf("Invalid LISTEN_FDS of systemd socket activation: '%s'", os.Getenv("LISTEN_FDS"))
+    }
+
+    var names []string
+    if s := os.Getenv("LISTEN_FDNAMES"); s != "" {
+        names = strings.Split(s, ":")
+    }
+
+    os.Unsetenv("LISTEN_PID")
+    os.Unsetenv("LISTEN_FDS")
+    os.Unsetenv("LISTEN_FDNAMES")
+
+    sockets = new(SystemdSockets)
+    for i := 0; i < n; i++ {
+        name := fmt.Sprintf("LISTEN_FD_%d", systemdFirstFD + i)
+        if i < len(names) {
+            name = names[i]
+        }
+
+        // net.FileListener duplicates the file descriptor, so the original one is closed
+        f := os.NewFile(uintptr(systemdFirstFD + i), name)
+        l, e := net.FileListener(f)
+        f.Close()
+        if e != nil {
+            err = fmt.Errorf("Socket '%s' passed by systemd is not a stream listener: %s", name, e)
+            return nil, err
+        }
+
+        if strings.ToLower(name) == "https" {
+            sockets.HTTPS = append(sockets.HTTPS, l)
+        } else {
+            sockets.HTTP = append(sockets.HTTP, l)
+        }
+    }
+
+    return
+}
+
+
+// Listen listens on a TCP address like 127.0.0.1:9100, or a Unix domain socket in the form of unix:<path>.
+func Listen(addr string) (net.Listener, error) {
+    if strings.HasPrefix(addr, "unix:") {
+        path, err := parseListenAddr(addr)
+        if err != nil {
+            return nil, err
+        }
+        return ListenUnix(path, &UnixSocketOption{})
+    }
+    return net.Listen("tcp", addr)
+}
+
+
+// check if an address could be used by Listen
+func checkListenAddr(addr string) error {
+    if strings.HasPrefix(addr, "unix:") {
+        _, err := parseListenAddr(addr)
+        return err
+    }
+    if _, _, err := net.SplitHostPort(addr); err != nil {
+        return fmt.Errorf("Listen address should be in the form of <host>:<port> or unix:<path>, got '%s'", addr)
+    }
+    return nil
+}
+
+
+// isLocalAddr checks if a listen address could only be connected from the local machine,
+// i.e. a Unix domain socket or a loopback address.
+func isLocalAddr(addr string) bool {
+    if strings.HasPrefix(addr, "unix:") {
+        return true
+    }
+    host, _, err := net.SplitHostPort(addr)
+    if err != nil {
+        return false
+    }
+    if host == "localhost" {
+        return true
+    }
+    ip := net.ParseIP(host)
+    return ip != nil && ip.IsLoopback()
+}
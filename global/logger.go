@@ -2,28 +2,80 @@ package global
 
 import "fmt"
 import "os"
+import "sync"
 import "github.com/m3ng9i/go-utils/log"
 
 
-var Logger *log.Logger
+var Logger *LevelLogger
 
 
-func createLogger(debug bool) {
+// configMu guards Config.Debug which could be changed by SetDebug, and the settings of the site
+// which could be changed by ReloadConfig, while requests are served.
+var configMu sync.RWMutex
+
+
+// LevelLogger is a log.Logger whose messages below INFO are written only if Config.Debug is true.
+// Level of the log.Logger is always DEBUG and never changed, so it's safe to be read by the goroutines of requests.
+type LevelLogger struct {
+    *log.Logger
+}
+
+
+func (this *LevelLogger) Debug(v ...interface{}) {
+    if IsDebug() {
+        this.Logger.Debug(v...)
+    }
+}
+
+
+func (this *LevelLogger) Debugf(format string, v ...interface{}) {
+    if IsDebug() {
+        this.Logger.Debugf(format, v...)
+    }
+}
+
+
+func (this *LevelLogger) Notice(v ...interface{}) {
+    if IsDebug() {
+        this.Logger.Notice(v...)
+    }
+}
+
+
+func (this *LevelLogger) Noticef(format string, v ...interface{}) {
+    if IsDebug() {
+        this.Logger.Noticef(format, v...)
+    }
+}
+
+
+func createLogger() {
     var config log.Config
     config.Layout       = log.LY_DEFAULT
     config.LayoutStyle  = log.LS_DEFAULT
     config.TimeFormat   = log.TF_DEFAULT
-    if debug {
-        config.Level = log.DEBUG
-    } else {
-        config.Level = log.INFO
-    }
+    config.Level        = log.DEBUG
 
-    var err error
-    Logger, err = log.New(os.Stdout, config)
+    l, err := log.New(os.Stdout, config)
     if err != nil {
-        fmt.Fprintf(os.Stderr, err.Error())
+        fmt.Fprintln(os.Stderr, err)
         os.Exit(1)
     }
+    Logger = &LevelLogger{l}
+}
+
+
+// IsDebug returns true if debug mode is on, it could be called while SetDebug is changing it.
+func IsDebug() bool {
+    configMu.RLock()
+    defer configMu.RUnlock()
+    return Config.Debug
 }
 
+
+// SetDebug changes the log level at runtime, DEBUG if debug is true, otherwise INFO.
+func SetDebug(debug bool) {
+    configMu.Lock()
+    Config.Debug = debug
+    configMu.Unlock()
+}
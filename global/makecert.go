@@ -1,43 +1,379 @@
 package global
 
+import "crypto"
+import "crypto/ecdsa"
+import "crypto/ed25519"
+import "crypto/elliptic"
+import "crypto/rand"
+import "crypto/rsa"
+import "crypto/tls"
+import "crypto/x509"
+import "crypto/x509/pkix"
+import "encoding/pem"
 import "fmt"
+import "math/big"
+import "net"
 import "os"
-import "github.com/m3ng9i/go-utils/tls"
+import "strings"
+import "time"
 
 
-func makeCertFiles(cert, key string, overwrite bool) error {
-    if cert == "" || key == "" {
-        return fmt.Errorf("Both certificate path and key path should be provided")
+type KeyType string
+const (
+    KeyRSA      KeyType = "rsa"
+    KeyECDSA    KeyType = "ecdsa"
+    KeyEd25519  KeyType = "ed25519"
+)
+
+
+// default validity of certificates, in days
+const (
+    DefaultCertDays     = 3650  // self-signed certificates
+    DefaultSignedDays   = 825   // certificates signed by a local CA, the maximum validity accepted by macOS and iOS
+    DefaultAutoCertDays = 365   // in-memory certificates created by -tls-auto
+)
+
+
+// CertOption contains options used to generate a certificate and a private key by -make-cert.
+type CertOption struct {
+    Cert        string      // Path of the certificate to be created.
+    Key         string      // Path of the private key to be created.
+    DNSNames    []string    // DNS subject alternative names.
+    IPs         []net.IP    // IP subject alternative names.
+    ClientCN    string      // If not empty, generate a client certificate with the common name instead of a server certificate.
+    Days        int         // Validity of the certificate in days. 0 means DefaultCertDays or DefaultSignedDays.
+    KeyType     KeyType     // Type of the private key. Default is KeyRSA.
+    CACert      string      // If not empty, the certificate is signed by the local CA, which is created if it does not exist.
+    CAKey       string      // Private key of the local CA.
+    Overwrite   bool        // If true, overwrite the existing certificate and private key.
+}
+
+
+// defaultCertHosts returns subject alternative names of a server certificate for the bind IPs:
+// localhost, the hostname and the bind IPs. If bind to all addresses, IPs of all interfaces are used.
+func defaultCertHosts(bindIPs []string) (dnsNames []string, ips []net.IP) {
+    dnsNames = append(dnsNames, "localhost")
+    if hostname, err := os.Hostname(); err == nil && hostname != "" && hostname != "localhost" {
+        dnsNames = append(dnsNames, hostname)
     }
 
-    // if the certificate or private key is exist, return an error
-    if !overwrite {
-        certExist := false
-        keyExist := false
-        if _, err := os.Stat(cert); err == nil {
-            certExist = true
+    for _, item := range bindIPs {
+        // remove brackets and scope of an IPv6 address, e.g. [fe80::1%2]
+        item = strings.Trim(item, "[]")
+        item = strings.SplitN(item, "%", 2)[0]
+
+        ip := net.ParseIP(item)
+        if ip == nil {
+            continue
         }
-        if _, err := os.Stat(key); err == nil {
-            keyExist = true
+
+        if !ip.IsUnspecified() {
+            ips = append(ips, ip)
+            continue
         }
-        if certExist && keyExist {
-            return fmt.Errorf("Certificate and private key are all exist, remove them and try again.")
+
+        addrs, err := net.InterfaceAddrs()
+        if err != nil {
+            ips = append(ips, net.IPv4(127, 0, 0, 1), net.IPv6loopback)
+            continue
+        }
+        for _, addr := range addrs {
+            if ipnet, ok := addr.(*net.IPNet); ok {
+                ips = append(ips, ipnet.IP)
+            }
+        }
+    }
+    return
+}
+
+
+// parse comma separated IP addresses
+func parseCertIPs(value string) (ips []net.IP, err error) {
+    for _, item := range strings.Split(value, ",") {
+        item = strings.TrimSpace(item)
+        if item == "" {
+            continue
+        }
+        ip := net.ParseIP(item)
+        if ip == nil {
+            err = fmt.Errorf("Invalid IP: %s", item)
+            return
+        }
+        ips = append(ips, ip)
+    }
+    return
+}
+
+
+// generate a private key of the key type
+func generateKey(keyType KeyType) (crypto.Signer, error) {
+    switch keyType {
+        case KeyRSA, "":
+            return rsa.GenerateKey(rand.Reader, 2048)
+        case KeyECDSA:
+            return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+        case KeyEd25519:
+            _, key, err := ed25519.GenerateKey(rand.Reader)
+            return key, err
+    }
+    return nil, fmt.Errorf(`Unknown key type '%s', valid values are "rsa", "ecdsa" and "ed25519"`, keyType)
+}
+
+
+// makeCertFiles generates a certificate and a private key. If option.CACert is set, the certificate is signed
+// by the local CA, otherwise it is self-signed. caCreated reports if a new local CA is created.
+func makeCertFiles(option *CertOption) (caCreated bool, err error) {
+    if option.Cert == "" || option.Key == "" {
+        err = fmt.Errorf("Both certificate path and key path should be provided")
+        return
+    }
+
+    // if the certificate or private key is exist, return an error
+    if !option.Overwrite {
+        if err = checkCertFilesExist(option.Cert, option.Key); err != nil {
+            return
         }
-        if certExist {
-            return fmt.Errorf("Certificate is exist, remove it and try again.")
+    }
+
+    if option.ClientCN == "" && len(option.DNSNames) == 0 && len(option.IPs) == 0 {
+        err = fmt.Errorf("At least one DNS name or IP address should be provided")
+        return
+    }
+
+    var ca *x509.Certificate
+    var signer crypto.Signer
+    if option.CACert != "" || option.CAKey != "" {
+        ca, signer, caCreated, err = loadOrMakeCA(option.CACert, option.CAKey)
+        if err != nil {
+            return
         }
-        if keyExist {
-            return fmt.Errorf("Private key is exist, remove it and try again.")
+    }
+
+    der, key, err := createCertificate(option, ca, signer)
+    if err != nil {
+        return
+    }
+
+    err = writeCertFiles(option.Cert, option.Key, der, key)
+    return
+}
+
+
+// createCertificate creates a certificate and it's private key, the certificate is signed by ca,
+// if ca is nil, the certificate is self-signed.
+func createCertificate(option *CertOption, ca *x509.Certificate, signer crypto.Signer) (der []byte, key crypto.Signer, err error) {
+    key, err = generateKey(option.KeyType)
+    if err != nil {
+        return
+    }
+
+    serial, err := randomSerialNumber()
+    if err != nil {
+        return
+    }
+
+    days := option.Days
+    if days <= 0 {
+        days = DefaultCertDays
+        if ca != nil {
+            days = DefaultSignedDays
         }
     }
 
-    // generate certificate and private key
+    now := time.Now()
+    template := &x509.Certificate {
+        SerialNumber:           serial,
+        Subject:                pkix.Name{Organization: []string{"RanServer"}},
+        NotBefore:              now.Add(-time.Hour),
+        NotAfter:               now.AddDate(0, 0, days),
+        KeyUsage:               x509.KeyUsageDigitalSignature,
+        BasicConstraintsValid:  true,
+    }
+
+    if option.ClientCN != "" {
+        template.Subject.CommonName = option.ClientCN
+        template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+    } else {
+        template.DNSNames = option.DNSNames
+        template.IPAddresses = option.IPs
+        template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+    }
+
+    // RSA key exchange of TLS 1.0-1.2 needs key encipherment
+    if _, ok := key.(*rsa.PrivateKey); ok {
+        template.KeyUsage |= x509.KeyUsageKeyEncipherment
+    }
+
+    // self-signed
+    parent := template
+    if ca != nil {
+        parent = ca
+    } else {
+        signer = key
+    }
+
+    der, err = x509.CreateCertificate(rand.Reader, template, parent, key.Public(), signer)
+    return
+}
+
+
+// AutoCertificate creates an in-memory self-signed certificate for localhost, the hostname and the bind IPs.
+func AutoCertificate(bindIPs []string) (*tls.Certificate, error) {
+    option := &CertOption {
+        Days:       DefaultAutoCertDays,
+        KeyType:    KeyECDSA,
+    }
+    option.DNSNames, option.IPs = defaultCertHosts(bindIPs)
+
+    der, key, err := createCertificate(option, nil, nil)
+    if err != nil {
+        return nil, err
+    }
+
+    leaf, err := x509.ParseCertificate(der)
+    if err != nil {
+        return nil, err
+    }
+
+    return &tls.Certificate {
+        Certificate:    [][]byte{der},
+        PrivateKey:     key,
+        Leaf:           leaf,
+    }, nil
+}
+
+
+// write a PEM block to a file, the file is created with mode perm.
+func writePEMFile(file, blockType string, bytes []byte, perm os.FileMode) error {
+    f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+    if err != nil {
+        return err
+    }
+    if err = pem.Encode(f, &pem.Block{Type: blockType, Bytes: bytes}); err != nil {
+        f.Close()
+        return err
+    }
+    return f.Close()
+}
+
+
+// write a certificate and it's private key to files
+func writeCertFiles(certFile, keyFile string, der []byte, key crypto.Signer) error {
+    keyBytes, err := x509.MarshalPKCS8PrivateKey(key)
+    if err != nil {
+        return err
+    }
+    if err = writePEMFile(certFile, "CERTIFICATE", der, 0644); err != nil {
+        return err
+    }
+    return writePEMFile(keyFile, "PRIVATE KEY", keyBytes, 0600)
+}
+
+
+func randomSerialNumber() (*big.Int, error) {
+    return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
+
+
+// create a local CA and write it's certificate and private key to files
+func makeCAFiles(certFile, keyFile string) (cert *x509.Certificate, key crypto.Signer, err error) {
+    key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+    if err != nil {
+        return
+    }
+
+    serial, err := randomSerialNumber()
+    if err != nil {
+        return
+    }
+
+    now := time.Now()
+    template := &x509.Certificate {
+        SerialNumber:           serial,
+        Subject:                pkix.Name{Organization: []string{"RanServer"}, CommonName: "RanServer Local CA"},
+        NotBefore:              now.Add(-time.Hour),
+        NotAfter:               now.AddDate(10, 0, 0),
+        KeyUsage:               x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+        BasicConstraintsValid:  true,
+        IsCA:                   true,
+        MaxPathLenZero:         true,
+    }
+
+    der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+    if err != nil {
+        return
+    }
+    if err = writeCertFiles(certFile, keyFile, der, key); err != nil {
+        return
+    }
+
+    cert, err = x509.ParseCertificate(der)
+    return
+}
+
 
-    option := tls.DefaultCertOption()
-    option.PublicKey    = cert
-    option.PrivateKey   = key
-    option.Organization = "RanServer"
+// load the certificate and private key of a local CA
+func loadCA(certFile, keyFile string) (cert *x509.Certificate, key crypto.Signer, err error) {
+    pair, err := tls.LoadX509KeyPair(certFile, keyFile)
+    if err != nil {
+        return
+    }
 
-    return tls.MakeCert(option)
+    cert, err = x509.ParseCertificate(pair.Certificate[0])
+    if err != nil {
+        return
+    }
+    if !cert.IsCA {
+        err = fmt.Errorf("'%s' is not a CA certificate", certFile)
+        return
+    }
+
+    key, ok := pair.PrivateKey.(crypto.Signer)
+    if !ok {
+        err = fmt.Errorf("Private key of the CA is not supported")
+    }
+    return
 }
 
+
+// load a local CA, if the CA files do not exist, create a new one.
+func loadOrMakeCA(certFile, keyFile string) (cert *x509.Certificate, key crypto.Signer, created bool, err error) {
+    if certFile == "" || keyFile == "" {
+        err = fmt.Errorf("Both CA certificate path and CA key path should be provided")
+        return
+    }
+
+    _, certErr := os.Stat(certFile)
+    _, keyErr := os.Stat(keyFile)
+    if os.IsNotExist(certErr) && os.IsNotExist(keyErr) {
+        cert, key, err = makeCAFiles(certFile, keyFile)
+        created = true
+        return
+    }
+
+    cert, key, err = loadCA(certFile, keyFile)
+    return
+}
+
+
+// checkCertFilesExist returns an error if the certificate or private key is exist.
+func checkCertFilesExist(cert, key string) error {
+    certExist := false
+    keyExist := false
+    if _, err := os.Stat(cert); err == nil {
+        certExist = true
+    }
+    if _, err := os.Stat(key); err == nil {
+        keyExist = true
+    }
+    if certExist && keyExist {
+        return fmt.Errorf("Certificate and private key are all exist, remove them and try again.")
+    }
+    if certExist {
+        return fmt.Errorf("Certificate is exist, remove it and try again.")
+    }
+    if keyExist {
+        return fmt.Errorf("Private key is exist, remove it and try again.")
+    }
+    return nil
+}
@@ -0,0 +1,245 @@
+package global
+
+import "crypto/sha256"
+import "crypto/tls"
+import "crypto/x509"
+import "fmt"
+import "io/ioutil"
+import "strings"
+import "golang.org/x/crypto/acme"
+import "github.com/m3ng9i/ran/server"
+
+
+type ClientAuthMode string
+const (
+    ClientAuthOptional ClientAuthMode = "optional"
+    ClientAuthRequired ClientAuthMode = "required"
+)
+
+
+// ClientAuthOption contains options of client certificate authentication (mutual TLS).
+type ClientAuthOption struct {
+    CA      string          // Path of a PEM file of CA certificates used to verify client certificates.
+    Mode    ClientAuthMode  // optional: verify the client certificate if it is given; required: always require one.
+    Paths   []string        // If not empty, client certificates are only required under these paths.
+}
+
+const DefaultClientAuthMode = ClientAuthRequired
+
+
+// check if a client certificate is required in the TLS handshake.
+// if some paths are protected, the certificate is requested in the handshake and required by the handler.
+func (this *ClientAuthOption) requiredInHandshake() bool {
+    return this.Mode == ClientAuthRequired && len(this.Paths) == 0
+}
+
+
+var tlsVersions = map[string]uint16 {
+    "1.0": tls.VersionTLS10,
+    "1.1": tls.VersionTLS11,
+    "1.2": tls.VersionTLS12,
+    "1.3": tls.VersionTLS13,
+}
+
+
+// parseTLSVersion converts a version like 1.2 to a TLS version number, empty string is 0.
+func parseTLSVersion(value string) (uint16, error) {
+    value = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(value)), "tls")
+    if value == "" {
+        return 0, nil
+    }
+    v, ok := tlsVersions[value]
+    if !ok {
+        return 0, fmt.Errorf(`Unknown TLS version '%s', valid values are "1.0", "1.1", "1.2" and "1.3"`, value)
+    }
+    return v, nil
+}
+
+
+// tlsVersionName converts a TLS version number to a string like 1.2, 0 is "default".
+func tlsVersionName(version uint16) string {
+    for name, v := range tlsVersions {
+        if v == version {
+            return name
+        }
+    }
+    return "default"
+}
+
+
+// parseCipherSuites converts cipher suite names separated by comma to IDs,
+// names are the same as the constants of crypto/tls, e.g. TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256.
+func parseCipherSuites(value string) (ids []uint16, err error) {
+    suites := append(tls.CipherSuites(), tls.InsecureCipherSuites()...)
+
+    OUTER:
+    for _, name := range strings.Split(value, ",") {
+        name = strings.ToUpper(strings.TrimSpace(name))
+        if name == "" {
+            continue
+        }
+        for _, suite := range suites {
+            if suite.Name != name {
+                continue
+            }
+            if len(suite.SupportedVersions) == 1 && suite.SupportedVersions[0] == tls.VersionTLS13 {
+                err = fmt.Errorf("Cipher suites of TLS 1.3 are not configurable: %s", name)
+                return
+            }
+            ids = append(ids, suite.ID)
+            continue OUTER
+        }
+        err = fmt.Errorf("Unknown cipher suite '%s'", name)
+        return
+    }
+    return
+}
+
+
+// HTTP/2 requires one of the cipher suites if the cipher suites are configured.
+func hasHTTP2CipherSuite(ids []uint16) bool {
+    for _, id := range ids {
+        if id == tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 || id == tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256 {
+            return true
+        }
+    }
+    return false
+}
+
+
+func cipherSuiteNames(ids []uint16) string {
+    var names []string
+    for _, id := range ids {
+        names = append(names, tls.CipherSuiteName(id))
+    }
+    return strings.Join(names, ", ")
+}
+
+
+var tlsCurves = map[string]tls.CurveID {
+    "X25519":   tls.X25519,
+    "P256":     tls.CurveP256,
+    "P384":     tls.CurveP384,
+    "P521":     tls.CurveP521,
+}
+
+
+// parseCurves converts curve names separated by comma to curve IDs, valid names are X25519, P256, P384 and P521.
+func parseCurves(value string) (curves []tls.CurveID, err error) {
+    for _, name := range strings.Split(value, ",") {
+        name = strings.ToUpper(strings.TrimSpace(name))
+        name = strings.Replace(strings.TrimPrefix(name, "CURVE"), "-", "", 1)
+        if name == "" {
+            continue
+        }
+        curve, ok := tlsCurves[name]
+        if !ok {
+            err = fmt.Errorf(`Unknown curve '%s', valid values are "X25519", "P256", "P384" and "P521"`, name)
+            return
+        }
+        curves = append(curves, curve)
+    }
+    return
+}
+
+
+func curveNames(curves []tls.CurveID) string {
+    var names []string
+    for _, curve := range curves {
+        for name, c := range tlsCurves {
+            if c == curve {
+                names = append(names, name)
+            }
+        }
+    }
+    return strings.Join(names, ", ")
+}
+
+
+// CertFingerprint returns the SHA-256 fingerprint of a certificate, like AB:CD:...
+func CertFingerprint(cert *x509.Certificate) string {
+    sum := sha256.Sum256(cert.Raw)
+    hex := make([]string, len(sum))
+    for i, b := range sum {
+        hex[i] = fmt.Sprintf("%02X", b)
+    }
+    return strings.Join(hex, ":")
+}
+
+
+// load a PEM file of certificates to a certificate pool
+func loadCertPool(file string) (*x509.CertPool, error) {
+    pem, err := ioutil.ReadFile(file)
+    if err != nil {
+        return nil, err
+    }
+
+    pool := x509.NewCertPool()
+    if !pool.AppendCertsFromPEM(pem) {
+        return nil, fmt.Errorf("No certificate found in '%s'", file)
+    }
+    return pool, nil
+}
+
+
+// CertPairs returns the certificate files, the first one is the default certificate:
+// -cert and -key if they are provided, otherwise the first of -cert-pair or -cert-dir.
+func (this *TLSOption) CertPairs() (pairs server.CertPairs) {
+    if this.PublicKey != "" && this.PrivateKey != "" {
+        pairs = append(pairs, server.CertPair{Cert: this.PublicKey, Key: this.PrivateKey})
+    }
+    pairs = append(pairs, this.Certificates...)
+    return append(pairs, this.certDirPairs...)
+}
+
+
+// Apply sets TLS options to a tls.Config used by the HTTPS listeners.
+func (this *TLSOption) Apply(config *tls.Config) error {
+    // keep the settings of config if the options are not set
+    if this.MinVersion > 0 {
+        config.MinVersion = this.MinVersion
+    }
+    if this.MaxVersion > 0 {
+        config.MaxVersion = this.MaxVersion
+    }
+    if len(this.CipherSuites) > 0 {
+        config.CipherSuites = this.CipherSuites
+    }
+    if len(this.Curves) > 0 {
+        config.CurvePreferences = this.Curves
+    }
+    if this.NoSessionTickets {
+        config.SessionTicketsDisabled = true
+    }
+
+    if this.ClientAuth != nil {
+        pool, err := loadCertPool(this.ClientAuth.CA)
+        if err != nil {
+            return err
+        }
+        config.ClientCAs = pool
+
+        if this.ClientAuth.requiredInHandshake() {
+            config.ClientAuth = tls.RequireAndVerifyClientCert
+        } else {
+            config.ClientAuth = tls.VerifyClientCertIfGiven
+        }
+
+        // the ACME CA does not send a client certificate when validating a tls-alpn-01 challenge
+        if this.ACME != nil && this.ACME.Challenge == ACMETLSALPN01 {
+            config.GetConfigForClient = func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+                for _, proto := range hello.SupportedProtos {
+                    if proto == acme.ALPNProto {
+                        c := config.Clone()
+                        c.ClientAuth = tls.NoClientCert
+                        c.GetConfigForClient = nil
+                        return c, nil
+                    }
+                }
+                return nil, nil
+            }
+        }
+    }
+
+    return nil
+}
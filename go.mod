@@ -1,10 +1,23 @@
 module github.com/m3ng9i/ran
 
-go 1.12
+go 1.24
 
 require (
-	github.com/abbot/go-http-auth v0.4.0 // indirect
+	github.com/fsnotify/fsnotify v1.10.1
 	github.com/m3ng9i/go-utils v0.0.0-20160811013010-f9b7dc669fde
 	github.com/oxtoacart/bpool v0.0.0-20190530202638-03653db5a59c
-	golang.org/x/net v0.0.0-20190724013045-ca1201d0de80 // indirect
+	github.com/quic-go/quic-go v0.54.1
+	golang.org/x/crypto v0.31.0
+)
+
+require (
+	github.com/abbot/go-http-auth v0.4.0 // indirect
+	github.com/quic-go/qpack v0.5.1 // indirect
+	go.uber.org/mock v0.5.0 // indirect
+	golang.org/x/mod v0.18.0 // indirect
+	golang.org/x/net v0.28.0 // indirect
+	golang.org/x/sync v0.10.0 // indirect
+	golang.org/x/sys v0.28.0 // indirect
+	golang.org/x/text v0.21.0 // indirect
+	golang.org/x/tools v0.22.0 // indirect
 )
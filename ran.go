@@ -1,14 +1,21 @@
 package main
 
 import "syscall"
+import "crypto/tls"
 import "os/signal"
+import "errors"
+import "net"
 import "net/http"
 import "os"
 import "fmt"
 import "strings"
 import "sync"
+import "time"
 import "github.com/m3ng9i/ran/global"
 import "github.com/m3ng9i/ran/server"
+import "golang.org/x/crypto/acme/autocert"
+import "github.com/quic-go/quic-go"
+import "github.com/quic-go/quic-go/http3"
 
 
 // version information
@@ -23,7 +30,7 @@ var versionInfo = fmt.Sprintf("Version: %s, Branch: %s, Build: %s, Build time: %
 
 func catchSignal() {
     signal_channel := make(chan os.Signal, 1)
-    signal.Notify(signal_channel, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+    signal.Notify(signal_channel, syscall.SIGINT, syscall.SIGTERM)
     go func() {
         for value := range signal_channel {
             global.Logger.Infof("System: Catch signal: %s, Ran is going to shutdown", value.String())
@@ -34,21 +41,90 @@ func catchSignal() {
 }
 
 
-// Get all Listening address, like: http://127.0.0.1:8080. The return value is used for recording logs.
-func getListeningAddr() (addr []string, err error) {
-    for _, ip := range global.Config.IP {
-        if global.Config.TLS != nil {
-            if global.Config.TLS.Policy == global.TLSOnly {
-                addr = append(addr, fmt.Sprintf("https://%s:%d", ip, global.Config.TLS.Port))
-            } else {
+// catchReloadSignal reloads the config and the certificates when one of reloadSignals is caught.
+func catchReloadSignal(reload func() error) {
+    if len(reloadSignals) == 0 {
+        return
+    }
+
+    signal_channel := make(chan os.Signal, 1)
+    signal.Notify(signal_channel, reloadSignals...)
+    go func() {
+        for value := range signal_channel {
+            if err := reload(); err != nil {
+                global.Logger.Errorf("System: Catch signal: %s, reload error: %s", value.String(), err)
+                continue
+            }
+            global.Logger.Infof("System: Catch signal: %s, config and certificates are reloaded", value.String())
+        }
+    }()
+}
+
+
+// catchMaintenanceSignal turns on or off maintenance mode when one of maintenanceSignals is caught.
+func catchMaintenanceSignal(ran *server.RanServer) {
+    if len(maintenanceSignals) == 0 {
+        return
+    }
+
+    signal_channel := make(chan os.Signal, 1)
+    signal.Notify(signal_channel, maintenanceSignals...)
+    go func() {
+        for value := range signal_channel {
+            ran.SetMaintenance(!ran.Maintenance())
+
+            state := "off"
+            if ran.Maintenance() {
+                state = "on"
+            }
+            global.Logger.Infof("System: Catch signal: %s, maintenance mode is %s", value.String(), state)
+        }
+    }()
+}
+
+
+// Get listening addresses of an IP, like: http://127.0.0.1:8080. The return value is used for recording logs.
+// sockets: listeners passed by systemd socket activation, if not nil, TCP ports of the IP are not listened.
+func getIPListeningAddr(ip string, sockets *global.SystemdSockets) (addr []string) {
+    if global.Config.TLS != nil {
+        if sockets == nil {
+            if global.Config.TLS.Policy != global.TLSOnly && global.Config.Unix == nil {
                 addr = append(addr, fmt.Sprintf("http://%s:%d", ip, global.Config.Port))
-                addr = append(addr, fmt.Sprintf("https://%s:%d", ip, global.Config.TLS.Port))
             }
-        } else {
-            addr = append(addr, fmt.Sprintf("http://%s:%d", ip, global.Config.Port))
+            addr = append(addr, fmt.Sprintf("https://%s:%d", ip, global.Config.TLS.Port))
+        }
+        if global.Config.HTTP3 {
+            addr = append(addr, fmt.Sprintf("https://%s:%d (HTTP/3)", ip, global.Config.TLS.Port))
+        }
+    } else if sockets == nil && global.Config.Unix == nil {
+        addr = append(addr, fmt.Sprintf("http://%s:%d", ip, global.Config.Port))
+    }
+    return
+}
+
+
+// Get all Listening address, like: http://127.0.0.1:8080. The return value is used for recording logs.
+// sockets: listeners passed by systemd socket activation, if not nil, they replace the addresses of IP and port.
+func getListeningAddr(sockets *global.SystemdSockets) (addr []string, err error) {
+    if global.Config.Unix != nil {
+        for _, path := range global.Config.Unix.Paths {
+            addr = append(addr, "http://unix:" + path)
         }
     }
 
+    if sockets != nil {
+        for _, l := range sockets.HTTP {
+            addr = append(addr, fmt.Sprintf("http://%s (systemd)", l.Addr()))
+        }
+        for _, l := range sockets.HTTPS {
+            addr = append(addr, fmt.Sprintf("https://%s (systemd)", l.Addr()))
+        }
+    }
+
+    for _, ip := range global.Config.IP {
+        addr = append(addr, getIPListeningAddr(ip, sockets)...)
+    }
+
     if len(addr) == 0 {
         err = fmt.Errorf("No IP address provided")
         return
@@ -58,16 +134,36 @@ func getListeningAddr() (addr []string, err error) {
 }
 
 
-func startLog() {
+// fingerprint: SHA-256 fingerprint of the certificate created by -tls-auto, it is shown with the HTTPS addresses.
+// sockets: listeners passed by systemd socket activation.
+func startLog(fingerprint string, sockets *global.SystemdSockets) {
     msg := "System: Ran is running on "
 
-    if global.Config.TLS != nil {
+    if sockets != nil {
+        msg += "sockets passed by systemd"
+        if global.Config.Unix != nil {
+            msg += " and Unix domain sockets"
+        }
+    } else if global.Config.Unix != nil {
+        msg += "Unix domain sockets"
+        if global.Config.TLS != nil {
+            msg += fmt.Sprintf(" and HTTPS port %d", global.Config.TLS.Port)
+        }
+    } else if global.Config.TLS != nil {
         switch global.Config.TLS.Policy {
             case global.TLSRedirect:
+                if global.Config.TLS.Port == global.Config.Port {
+                    msg += fmt.Sprintf("port %d, HTTP traffic will redirect to HTTPS on the same port", global.Config.Port)
+                    break
+                }
                 msg += fmt.Sprintf("HTTPS port %d, all traffic from HTTP port %d will redirect to HTTPS port",
                     global.Config.TLS.Port, global.Config.Port)
 
             case global.TLSBoth:
+                if global.Config.TLS.Port == global.Config.Port {
+                    msg += fmt.Sprintf("port %d, both HTTP and HTTPS are enabled on the same port", global.Config.Port)
+                    break
+                }
                 msg += fmt.Sprintf("HTTP port %d and HTTPS port %d", global.Config.Port, global.Config.TLS.Port)
 
             case global.TLSOnly:
@@ -81,19 +177,98 @@ func startLog() {
         msg += fmt.Sprintf(" with %s auth", string(global.Config.Auth.Method))
     }
 
+    if global.Config.TLS != nil && global.Config.TLS.ClientAuth != nil {
+        msg += fmt.Sprintf(", client certificates are %s", global.Config.TLS.ClientAuth.Mode)
+    }
+
+    if global.Config.TLS != nil && global.Config.TLS.ACME != nil {
+        msg += fmt.Sprintf(", certificates of %s are managed by ACME (%s challenge)",
+            strings.Join(global.Config.TLS.ACME.Domains, ", "), global.Config.TLS.ACME.Challenge)
+    }
+
     global.Logger.Info(msg)
 
-    addr, err := getListeningAddr()
-    if err != nil {
+    addr, err := getListeningAddr(sockets)
+    if err != nil && global.Config.BindWatch > 0 {
+        global.Logger.Info("System: The network interfaces have no address, waiting for addresses to be assigned")
+    } else if err != nil {
         global.Logger.Error(err)
     } else {
         for _, i := range addr {
-            global.Logger.Infof("System: Listening on %s", i)
+            if fingerprint != "" && strings.HasPrefix(i, "https://") {
+                global.Logger.Infof("System: Listening on %s (certificate SHA-256 fingerprint: %s)", i, fingerprint)
+            } else {
+                global.Logger.Infof("System: Listening on %s", i)
+            }
+        }
+    }
+
+    if global.Config.Metrics != nil {
+        if global.Config.MetricsListen != "" {
+            global.Logger.Infof("System: Metrics are served on %s of %s", global.Config.Metrics.Path, global.Config.MetricsListen)
+        } else {
+            global.Logger.Infof("System: Metrics are served on %s of the site", global.Config.Metrics.Path)
+        }
+    }
+
+    if global.Config.LiveReload != nil {
+        global.Logger.Infof("System: Live reload is on, watching %s", global.Config.Root)
+    }
+
+    if global.Config.Maintenance != nil && global.Config.Maintenance.On {
+        global.Logger.Info("System: Maintenance mode is on")
+    }
+
+    if global.Config.AdminListen != "" {
+        global.Logger.Infof("System: Admin API is served on %s", global.Config.AdminListen)
+    }
+}
+
+
+// warnVirtualHostCertificates warns if the host name of a virtual host is not in any certificate,
+// clients of the host get the default certificate and a certificate error.
+// certLoaders is nil if certificates are managed by ACME or created by -tls-auto.
+func warnVirtualHostCertificates(certLoaders server.CertLoaders) {
+    for _, vh := range global.Config.VirtualHosts {
+        for _, host := range vh.Hosts {
+            var ok bool
+            switch {
+                case certLoaders != nil:
+                    ok = certLoaders.Match(host)
+                case global.Config.TLS.ACME != nil:
+                    for _, domain := range global.Config.TLS.ACME.Domains {
+                        if strings.EqualFold(domain, host) {
+                            ok = true
+                        }
+                    }
+                default:
+                    // the certificate of -tls-auto is for local addresses only
+                    ok = true
+            }
+            if !ok {
+                global.Logger.Warnf("System: No certificate matches virtual host '%s', the default certificate is used", host)
+            }
         }
     }
 }
 
 
+// acmeTLSConfig returns a TLS config which gets certificates from the ACME CA,
+// it also answers tls-alpn-01 challenges. Errors of getting certificates are logged.
+func acmeTLSConfig(m *autocert.Manager) *tls.Config {
+    config := m.TLSConfig()
+    getCertificate := config.GetCertificate
+    config.GetCertificate = func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+        cert, err := getCertificate(hello)
+        if err != nil {
+            global.Logger.Errorf("System: ACME: Get certificate of '%s' error: %s", hello.ServerName, err)
+        }
+        return cert, err
+    }
+    return config
+}
+
+
 func main() {
     global.LoadConfig(versionInfo)
 
@@ -115,72 +290,401 @@ func main() {
     var wg sync.WaitGroup
     defer wg.Wait()
 
-    startLog()
+    if global.Config.Health != nil {
+        global.Config.Health.Version = server.VersionInfo {
+            Version:    _version_,
+            Branch:     _branch_,
+            CommitId:   _commitId_,
+            BuildTime:  _buildTime_,
+        }
+    }
 
-    ran := server.NewRanServer(global.Config.Config, global.Logger)
+    // files are read from a directory or an archive
+    ran := server.New(global.Config.Files, server.WithConfig(global.Config.Config), server.WithLogger(global.Logger))
+    catchMaintenanceSignal(ran)
 
-    startHTTPServer := func() {
-        for _, ip := range global.Config.IP {
-            wg.Add(1)
-            go func(ip string) {
-                err := http.ListenAndServe(
-                        fmt.Sprintf("%s:%d", ip, global.Config.Port),
-                        ran.Serve(),
-                )
-                if err != nil {
-                    global.Logger.Fatal(err)
-                }
-                wg.Done()
-            }(ip)
+    if global.Config.LiveReload != nil {
+        if err := ran.StartLiveReload(); err != nil {
+            global.Logger.Fatal(err)
         }
     }
 
-    startTLSServer := func() {
-        for _, ip := range global.Config.IP {
-            wg.Add(1)
-            go func(ip string) {
-                err := http.ListenAndServeTLS(
-                        fmt.Sprintf("%s:%d", ip, global.Config.TLS.Port),
-                        global.Config.TLS.PublicKey,
-                        global.Config.TLS.PrivateKey,
-                        ran.Serve(),
-                )
-                if err != nil {
-                    global.Logger.Fatal(err)
-                }
-                wg.Done()
-            }(ip)
+    // connLimiter is shared by all the listeners
+    connLimiter := server.NewConnLimiter(int(global.Config.MaxConns), int(global.Config.MaxConnsPerIP), global.Logger)
+
+    // metrics is nil if metrics are not collected
+    metrics := ran.Metrics()
+    metrics.WatchConnLimiter(connLimiter)
+
+    // conns records open connections for the admin API, it is nil if the admin API is off
+    var conns *server.ConnTracker
+    if global.Config.AdminListen != "" {
+        conns = server.NewConnTracker()
+    }
+
+    // count open connections of a listener and limit the number of connections
+    limit := func(l net.Listener) net.Listener {
+        return connLimiter.Listener(conns.Listener(metrics.Listener(l)))
+    }
+
+    // listen on a TCP address and limit the number of connections
+    listen := func(addr string) (net.Listener, error) {
+        l, err := net.Listen("tcp", addr)
+        if err != nil {
+            return nil, err
+        }
+        return limit(l), nil
+    }
+
+    // acmeManager gets certificates from an ACME CA
+    var acmeManager *autocert.Manager
+    if global.Config.TLS != nil && global.Config.TLS.ACME != nil {
+        var err error
+        acmeManager, err = global.NewACMEManager(global.Config.TLS.ACME)
+        if err != nil {
+            global.Logger.Fatal(err)
         }
     }
 
-    redirectToHTTPS := func() {
-        for _, ip := range global.Config.IP {
+    // tlsConfig provides certificates to the HTTPS listeners
+    var tlsConfig *tls.Config
+    var fingerprint string
+    var certLoaders server.CertLoaders
+    if global.Config.TLS != nil {
+        if acmeManager != nil {
+            tlsConfig = acmeTLSConfig(acmeManager)
+        } else if global.Config.TLS.Auto {
+            cert, err := global.AutoCertificate(global.Config.IP)
+            if err != nil {
+                global.Logger.Fatal(err)
+            }
+            fingerprint = global.CertFingerprint(cert.Leaf)
+            tlsConfig = &tls.Config{Certificates: []tls.Certificate{*cert}}
+            ran.SetCertificate(func() *tls.Certificate { return cert })
+        } else {
+            var err error
+            certLoaders, err = server.NewCertLoaders(global.Config.TLS.CertPairs(), global.Logger)
+            if err != nil {
+                global.Logger.Fatal(err)
+            }
+            if global.Config.TLS.CertWatch > 0 {
+                go certLoaders.Watch(global.Config.TLS.CertWatch)
+            }
+            tlsConfig = &tls.Config{GetCertificate: certLoaders.GetCertificate}
+            ran.SetCertificate(certLoaders.Certificate)
+        }
+
+        warnVirtualHostCertificates(certLoaders)
+
+        if err := global.Config.TLS.Apply(tlsConfig); err != nil {
+            global.Logger.Fatal(err)
+        }
+    }
+
+    // sockets passed by systemd socket activation replace the listeners of -bind-ip, -port and -tls-port
+    sockets, err := global.SystemdListeners()
+    if err != nil {
+        global.Logger.Fatal(err)
+    }
+    if sockets != nil && len(sockets.HTTPS) > 0 && tlsConfig == nil {
+        global.Logger.Fatal("HTTPS sockets passed by systemd need TLS, use them with -cert and -key, -tls-auto or -acme-domain")
+    }
+
+    // Unix domain sockets take the place of the HTTP port
+    var unixListeners []net.Listener
+    if global.Config.Unix != nil {
+        for _, path := range global.Config.Unix.Paths {
+            l, err := global.ListenUnix(path, global.Config.Unix)
+            if err != nil {
+                global.Logger.Fatal(err)
+            }
+            unixListeners = append(unixListeners, l)
+        }
+    }
+
+    // metrics are served by a separate listener, it's connections are not counted or limited
+    var metricsListener net.Listener
+    if global.Config.MetricsListen != "" {
+        metricsListener, err = global.Listen(global.Config.MetricsListen)
+        if err != nil {
+            global.Logger.Fatal(err)
+        }
+    }
+
+    // admin API is served by a separate listener, it's connections are not counted or limited
+    var adminListener net.Listener
+    if global.Config.AdminListen != "" {
+        adminListener, err = global.Listen(global.Config.AdminListen)
+        if err != nil {
+            global.Logger.Fatal(err)
+        }
+    }
+
+    startLog(fingerprint, sockets)
+
+    // handler is shared by all the listeners
+    handler := ran.Serve()
+
+    // h3Server serves HTTP/3 on the UDP port of HTTPS, it is advertised by the Alt-Svc header of HTTPS responses
+    var h3Server *http3.Server
+    if global.Config.TLS != nil && global.Config.HTTP3 {
+        h3Server = &http3.Server {
+            Handler:    handler,
+            TLSConfig:  tlsConfig,
+            Port:       int(global.Config.TLS.Port),
+            QUICConfig: &quic.Config{Allow0RTT: false}, // 0-RTT requests could be replayed
+        }
+    }
+
+    // server of the HTTP port: answer ACME http-01 challenges, other requests are sent to handler.
+    // if h2c is on, HTTP/2 without TLS is accepted.
+    newHTTPServer := func(handler http.Handler) *http.Server {
+        if acmeManager != nil && global.Config.TLS.ACME.Challenge == global.ACMEHTTP01 {
+            handler = acmeManager.HTTPHandler(handler)
+        }
+        server := &http.Server{Handler: handler}
+        global.Config.HTTP2.ConfigureServer(server, false)
+        return server
+    }
+
+    // server of the HTTPS port
+    newTLSServer := func() *http.Server {
+        tlsHandler := handler
+        if h3Server != nil {
+            tlsHandler = func(w http.ResponseWriter, r *http.Request) {
+                // error is returned if no UDP listener is ready, the header is not set in this case
+                h3Server.SetQUICHeaders(w.Header())
+                handler(w, r)
+            }
+        }
+
+        server := &http.Server{Handler: tlsHandler, TLSConfig: tlsConfig}
+        global.Config.HTTP2.ConfigureServer(server, true)
+        return server
+    }
+
+    // serve HTTP or HTTPS on a listener in a new goroutine, requests of HTTP are sent to plainHandler.
+    // the error returned after the listener is closed (e.g. the address is removed from the network interface) is ignored.
+    serve := func(l net.Listener, plainHandler http.HandlerFunc, useTLS bool) {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+
+            var err error
+            if useTLS {
+                err = newTLSServer().ServeTLS(l, "", "")
+            } else {
+                err = newHTTPServer(plainHandler).Serve(l)
+            }
+            if err != nil && !errors.Is(err, net.ErrClosed) {
+                global.Logger.Fatal(err)
+            }
+        }()
+    }
+
+    // handler of the HTTP port
+    plainHandler := handler
+    if global.Config.TLS != nil && global.Config.TLS.Policy == global.TLSRedirect {
+        plainHandler = ran.RedirectToHTTPS(global.Config.TLS.Port)
+    }
+
+    // bind listens on the ports of an IP address and serves on them, closeAll closes the listeners.
+    // if sockets are passed by systemd, only the UDP port of HTTP/3 is listened.
+    bind := func(ip string) (closeAll func(), err error) {
+        var tlsListener, httpListener net.Listener
+        var conn net.PacketConn
+
+        closeAll = func() {
+            for _, l := range []net.Listener{tlsListener, httpListener} {
+                if l != nil {
+                    l.Close()
+                }
+            }
+            if conn != nil {
+                conn.Close()
+            }
+        }
+
+        defer func() {
+            if err != nil {
+                closeAll()
+            }
+        }()
+
+        if sockets == nil && global.Config.TLS != nil && global.Config.TLS.Policy != global.TLSOnly &&
+            global.Config.TLS.Port == global.Config.Port && global.Config.Unix == nil {
+            // serve HTTP and HTTPS on the same port, connections are split by the first byte.
+
+            var l net.Listener
+            if l, err = listen(fmt.Sprintf("%s:%d", ip, global.Config.Port)); err != nil {
+                return
+            }
+            tlsListener, httpListener = server.SplitListener(l)
+        } else if sockets == nil {
+            if global.Config.TLS != nil {
+                if tlsListener, err = listen(fmt.Sprintf("%s:%d", ip, global.Config.TLS.Port)); err != nil {
+                    return
+                }
+            }
+
+            // Unix domain sockets take the place of the HTTP port
+            if global.Config.Unix == nil && (global.Config.TLS == nil || global.Config.TLS.Policy != global.TLSOnly) {
+                if httpListener, err = listen(fmt.Sprintf("%s:%d", ip, global.Config.Port)); err != nil {
+                    return
+                }
+            }
+        }
+
+        if h3Server != nil {
+            if conn, err = net.ListenPacket("udp", fmt.Sprintf("%s:%d", ip, global.Config.TLS.Port)); err != nil {
+                return
+            }
+        }
+
+        if tlsListener != nil {
+            serve(tlsListener, nil, true)
+        }
+        if httpListener != nil {
+            serve(httpListener, plainHandler, false)
+        }
+        if conn != nil {
             wg.Add(1)
-            go func(ip string) {
-                err := http.ListenAndServe(
-                    fmt.Sprintf("%s:%d", ip, global.Config.Port),
-                    ran.RedirectToHTTPS(global.Config.TLS.Port),
-                )
-                if err != nil {
+            go func() {
+                defer wg.Done()
+                err := h3Server.Serve(conn)
+                if err != nil && !errors.Is(err, net.ErrClosed) {
                     global.Logger.Fatal(err)
                 }
-                wg.Done()
-            }(ip)
+            }()
         }
+
+        return
     }
 
-    if global.Config.TLS != nil {
-        // turn on TLS encryption
+    // requests from Unix domain sockets are served without redirecting to HTTPS. TLS, client certificates and HSTS
+    // are handled by the reverse proxy in front of ran.
+    for _, l := range unixListeners {
+        serve(limit(l), handler, false)
+    }
+
+    if metricsListener != nil {
+        mux := http.NewServeMux()
+        mux.Handle(global.Config.Metrics.Path, metrics)
+
+        wg.Add(1)
+        go func() {
+            err := http.Serve(metricsListener, mux)
+            if err != nil {
+                global.Logger.Fatal(err)
+            }
+            wg.Done()
+        }()
+    }
 
-        startTLSServer()
+    // reload loads the certificates, the command line and the config file again,
+    // settings of the listeners, TLS and the others which need a restart are not changed.
+    reload := func() error {
+        if certLoaders != nil {
+            if err := certLoaders.Reload(); err != nil {
+                return err
+            }
+        }
+        c, files, err := global.ReloadConfig()
+        if err != nil {
+            return err
+        }
+        ran.SetConfig(c, files)
+        return nil
+    }
+    catchReloadSignal(reload)
+
+    if adminListener != nil {
+        admin := &server.Admin {
+            Server:     ran,
+            Logger:     global.Logger,
+            Conns:      conns,
+            Config:     global.Config.String,
+            Reload:     reload,
+            Debug:      global.IsDebug,
+            SetDebug:   global.SetDebug,
+        }
+        if global.Config.AdminAuth != nil {
+            admin.Username = global.Config.AdminAuth.Username
+            admin.Password = global.Config.AdminAuth.Password
+        }
 
-        if global.Config.TLS.Policy == global.TLSRedirect {
-            redirectToHTTPS()
-        } else if global.Config.TLS.Policy == global.TLSBoth {
-            startHTTPServer()
+        wg.Add(1)
+        go func() {
+            err := http.Serve(adminListener, admin.Handler())
+            if err != nil {
+                global.Logger.Fatal(err)
+            }
+            wg.Done()
+        }()
+    }
+
+    // listeners are opened by systemd
+    if sockets != nil {
+        for _, l := range sockets.HTTPS {
+            serve(limit(l), nil, true)
+        }
+        for _, l := range sockets.HTTP {
+            serve(limit(l), plainHandler, false)
         }
-    } else {
-        startHTTPServer()
     }
 
+    // bound maps IP addresses to the functions closing their listeners
+    bound := make(map[string]func())
+    for _, ip := range global.Config.IP {
+        closeAll, err := bind(ip)
+        if err != nil {
+            global.Logger.Fatal(err)
+        }
+        bound[ip] = closeAll
+    }
+
+    // check addresses of the network interfaces in -bind-ip, listen on new addresses and close listeners of removed ones.
+    if global.Config.BindWatch > 0 {
+        // never done, so ran keeps running when all the addresses are removed
+        wg.Add(1)
+
+        go func() {
+            for range time.Tick(global.Config.BindWatch) {
+                ips, err := global.Config.CurrentIPs()
+                if err != nil {
+                    global.Logger.Errorf("System: Get addresses of the network interfaces error: %s", err)
+                    continue
+                }
+
+                current := make(map[string]bool)
+                for _, ip := range ips {
+                    current[ip] = true
+                    if _, ok := bound[ip]; ok {
+                        continue
+                    }
+
+                    // failed binding is tried again at the next check, e.g. an IPv6 address is not ready
+                    closeAll, err := bind(ip)
+                    if err != nil {
+                        global.Logger.Errorf("System: Listen on new address %s error: %s", ip, err)
+                        continue
+                    }
+                    bound[ip] = closeAll
+
+                    global.Logger.Infof("System: Address %s is added to the network interface", ip)
+                    for _, addr := range getIPListeningAddr(ip, sockets) {
+                        global.Logger.Infof("System: Listening on %s", addr)
+                    }
+                }
+
+                for ip, closeAll := range bound {
+                    if !current[ip] {
+                        closeAll()
+                        delete(bound, ip)
+                        global.Logger.Infof("System: Address %s is removed from the network interface, stop listening on it", ip)
+                    }
+                }
+            }
+        }()
+    }
 }
@@ -0,0 +1,252 @@
+package server
+
+import "encoding/json"
+import "net"
+import "net/http"
+import "sort"
+import "strings"
+import "sync"
+import "time"
+import hhelper "github.com/m3ng9i/go-utils/http"
+
+
+// ConnInfo describes an open connection.
+type ConnInfo struct {
+    Listener    string      `json:"listener"`
+    Remote      string      `json:"remote"`
+    Since       time.Time   `json:"since"`
+}
+
+
+// ConnTracker records open connections of listeners, so that they could be inspected by the admin API.
+// Methods of a nil ConnTracker do nothing.
+type ConnTracker struct {
+    mu      sync.Mutex
+    conns   map[*trackedConn]ConnInfo
+}
+
+
+func NewConnTracker() *ConnTracker {
+    return &ConnTracker{conns: make(map[*trackedConn]ConnInfo)}
+}
+
+
+// Listener wraps a net.Listener to record it's connections.
+func (this *ConnTracker) Listener(l net.Listener) net.Listener {
+    if this == nil {
+        return l
+    }
+    return &trackedListener{Listener: l, tracker: this}
+}
+
+
+// Connections returns the open connections, the oldest is the first.
+func (this *ConnTracker) Connections() []ConnInfo {
+    this.mu.Lock()
+    conns := make([]ConnInfo, 0, len(this.conns))
+    for _, info := range this.conns {
+        conns = append(conns, info)
+    }
+    this.mu.Unlock()
+
+    sort.Slice(conns, func(i, j int) bool {
+        return conns[i].Since.Before(conns[j].Since)
+    })
+    return conns
+}
+
+
+type trackedListener struct {
+    net.Listener
+    tracker *ConnTracker
+}
+
+
+func (this *trackedListener) Accept() (net.Conn, error) {
+    c, err := this.Listener.Accept()
+    if err != nil {
+        return nil, err
+    }
+
+    tc := &trackedConn{Conn: c, tracker: this.tracker}
+    this.tracker.mu.Lock()
+    this.tracker.conns[tc] = ConnInfo {
+        Listener:   this.Addr().String(),
+        Remote:     c.RemoteAddr().String(),
+        Since:      time.Now(),
+    }
+    this.tracker.mu.Unlock()
+    return tc, nil
+}
+
+
+type trackedConn struct {
+    net.Conn
+    tracker *ConnTracker
+}
+
+
+func (this *trackedConn) Close() error {
+    this.tracker.mu.Lock()
+    delete(this.tracker.conns, this)
+    this.tracker.mu.Unlock()
+    return this.Conn.Close()
+}
+
+
+// Admin serves the admin API in JSON:
+//
+//  GET /config                 effective config
+//  GET /connections            open connections
+//  GET /maintenance            if maintenance mode is on
+//  PUT /maintenance            turn on or off maintenance mode, body: {"enabled": true}
+//  POST /reload                reload the config and certificates
+//  GET /log-level              current log level
+//  PUT /log-level              change log level, body: {"level": "debug"} or {"level": "info"}
+//
+// Functions of the main package are provided by the fields.
+type Admin struct {
+    Server      *RanServer
+    Logger      Logger
+    Conns       *ConnTracker
+    Config      func() string       // Returns the effective config, a "<name>: <value>" pair per line.
+    Reload      func() error        // Reloads the config and certificates, e.g. by RanServer.SetConfig.
+    Debug       func() bool         // Returns true if the log level is DEBUG.
+    SetDebug    func(bool)          // Changes the log level to DEBUG or INFO.
+    Username    string              // If not empty, the admin API needs basic authentication.
+    Password    string
+}
+
+
+type adminError struct {
+    Error string `json:"error"`
+}
+
+
+// Handler returns the request handler of the admin API.
+func (this *Admin) Handler() http.Handler {
+    mux := http.NewServeMux()
+
+    mux.HandleFunc("/config", this.method(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+        config := make(map[string]string)
+        for _, line := range strings.Split(this.Config(), "\n") {
+            pair := strings.SplitN(strings.TrimSpace(line), ": ", 2)
+            if len(pair) == 2 {
+                config[pair[0]] = pair[1]
+            }
+        }
+        writeJSON(w, http.StatusOK, config)
+    }))
+
+    mux.HandleFunc("/connections", this.method(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+        writeJSON(w, http.StatusOK, this.Conns.Connections())
+    }))
+
+    mux.HandleFunc("/maintenance", func(w http.ResponseWriter, r *http.Request) {
+        var v struct {
+            Enabled bool `json:"enabled"`
+        }
+
+        switch r.Method {
+            case http.MethodGet:
+            case http.MethodPut:
+                if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+                    writeJSON(w, http.StatusBadRequest, adminError{"Invalid JSON: " + err.Error()})
+                    return
+                }
+                this.Server.SetMaintenance(v.Enabled)
+                this.Logger.Infof("System: Admin: Maintenance mode is %s", onOff(v.Enabled))
+            default:
+                writeJSON(w, http.StatusMethodNotAllowed, adminError{"Method not allowed"})
+                return
+        }
+
+        v.Enabled = this.Server.Maintenance()
+        writeJSON(w, http.StatusOK, v)
+    })
+
+    mux.HandleFunc("/reload", this.method(http.MethodPost, func(w http.ResponseWriter, r *http.Request) {
+        if err := this.Reload(); err != nil {
+            this.Logger.Errorf("System: Admin: Reload error: %s", err)
+            writeJSON(w, http.StatusInternalServerError, adminError{err.Error()})
+            return
+        }
+        this.Logger.Info("System: Admin: Config and certificates are reloaded")
+        writeJSON(w, http.StatusOK, healthStatus{Status: "ok"})
+    }))
+
+    mux.HandleFunc("/log-level", func(w http.ResponseWriter, r *http.Request) {
+        var v struct {
+            Level string `json:"level"`
+        }
+
+        switch r.Method {
+            case http.MethodGet:
+            case http.MethodPut:
+                if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+                    writeJSON(w, http.StatusBadRequest, adminError{"Invalid JSON: " + err.Error()})
+                    return
+                }
+                switch strings.ToLower(v.Level) {
+                    case "debug":
+                        this.SetDebug(true)
+                    case "info":
+                        this.SetDebug(false)
+                    default:
+                        writeJSON(w, http.StatusBadRequest, adminError{`Log level could only be "debug" or "info"`})
+                        return
+                }
+                this.Logger.Infof("System: Admin: Log level is changed to %s", strings.ToLower(v.Level))
+            default:
+                writeJSON(w, http.StatusMethodNotAllowed, adminError{"Method not allowed"})
+                return
+        }
+
+        v.Level = "info"
+        if this.Debug() {
+            v.Level = "debug"
+        }
+        writeJSON(w, http.StatusOK, v)
+    })
+
+    mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+        writeJSON(w, http.StatusNotFound, adminError{"Not found"})
+    })
+
+    handler := http.HandlerFunc(mux.ServeHTTP)
+    if this.Username != "" {
+        ba := hhelper.BasicAuth {
+            Realm:  "Ran admin",
+            Secret: hhelper.BasicAuthSecret(this.Username, this.Password),
+        }
+        failMsg := &hhelper.AuthFile {
+            ContentType:    "application/json; charset=utf-8",
+            Body:           []byte(`{"error":"Unauthorized"}`),
+        }
+        handler = ba.BasicAuthHandler(handler, failMsg, func() {
+            // sleep 300~2499 milliseconds to prevent brute force attack
+            time.Sleep(time.Duration(randTime()) * time.Millisecond)
+        })
+    }
+    return handler
+}
+
+
+// method returns 405 Method Not Allowed if the request method is not m.
+func (this *Admin) method(m string, fn http.HandlerFunc) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        if r.Method != m {
+            writeJSON(w, http.StatusMethodNotAllowed, adminError{"Method not allowed"})
+            return
+        }
+        fn(w, r)
+    }
+}
+
+
+func onOff(on bool) string {
+    if on {
+        return "on"
+    }
+    return "off"
+}
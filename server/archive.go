@@ -0,0 +1,406 @@
+package server
+
+import "archive/tar"
+import "archive/zip"
+import "bytes"
+import "compress/gzip"
+import "errors"
+import "fmt"
+import "io"
+import "io/fs"
+import "os"
+import "path"
+import "sort"
+import "strings"
+import "time"
+
+
+// extensions of the archives which could be served as a site
+var archiveExts = []string{".zip", ".tar", ".tar.gz", ".tgz"}
+
+
+// IsArchive checks if a file name has the extension of an archive which could be served, e.g. docs.tar.gz.
+func IsArchive(name string) bool {
+    name = strings.ToLower(name)
+    for _, ext := range archiveExts {
+        if strings.HasSuffix(name, ext) {
+            return true
+        }
+    }
+    return false
+}
+
+
+// OpenRoot returns the files of a root, which is a directory, or an archive file (.zip, .tar, .tar.gz or .tgz).
+func OpenRoot(root string) (fs.FS, error) {
+    if IsArchive(root) {
+        return OpenArchive(root)
+    }
+    return os.DirFS(root), nil
+}
+
+
+// OpenArchive opens a .zip, .tar, .tar.gz or .tgz file as a read-only fs.FS.
+// The archive file is kept open to read the entries.
+// Entries of a .tar file and uncompressed entries of a .zip file are read from the file directly,
+// compressed entries of a .zip file are decompressed while they are read, entries of a .tar.gz file are read into memory.
+func OpenArchive(name string) (fs.FS, error) {
+    lower := strings.ToLower(name)
+
+    f, err := os.Open(name)
+    if err != nil {
+        return nil, err
+    }
+
+    info, err := f.Stat()
+    if err != nil {
+        f.Close()
+        return nil, err
+    }
+
+    if strings.HasSuffix(lower, ".zip") {
+        r, err := zip.NewReader(f, info.Size())
+        if err != nil {
+            f.Close()
+            return nil, err
+        }
+        return newZipFS(r, f), nil
+    }
+
+    if strings.HasSuffix(lower, ".tar") {
+        fsys, err := newTarFS(f, f, info.ModTime())
+        if err != nil {
+            f.Close()
+            return nil, err
+        }
+        return fsys, nil
+    }
+
+    defer f.Close()
+    gz, err := gzip.NewReader(f)
+    if err != nil {
+        return nil, err
+    }
+    return newTarFS(gz, nil, info.ModTime())
+}
+
+
+// zipFS is a read-only fs.FS of a zip archive, regular files of it are seekable so that range requests are supported.
+type zipFS struct {
+    *zip.Reader
+    file    *os.File
+    headers map[*zip.FileHeader]*zip.File   // find the entry of a file opened by zip.Reader
+}
+
+
+func newZipFS(r *zip.Reader, file *os.File) *zipFS {
+    this := &zipFS {
+        Reader:     r,
+        file:       file,
+        headers:    make(map[*zip.FileHeader]*zip.File),
+    }
+    for _, f := range r.File {
+        this.headers[&f.FileHeader] = f
+    }
+    return this
+}
+
+
+func (this *zipFS) Close() error {
+    return this.file.Close()
+}
+
+
+func (this *zipFS) Open(name string) (fs.File, error) {
+    f, err := this.Reader.Open(name)
+    if err != nil {
+        return nil, err
+    }
+
+    info, err := f.Stat()
+    if err != nil {
+        f.Close()
+        return nil, err
+    }
+    hdr, _ := info.Sys().(*zip.FileHeader)
+    entry := this.headers[hdr]
+    if info.IsDir() || entry == nil {
+        return f, nil
+    }
+
+    // an uncompressed entry is read from the archive file by it's offset
+    if entry.Method == zip.Store {
+        f.Close()
+        offset, err := entry.DataOffset()
+        if err != nil {
+            return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+        }
+        return &archiveFile{SectionReader: io.NewSectionReader(this.file, offset, info.Size()), info: info}, nil
+    }
+
+    f.Close()
+    return &zipEntry{entry: entry, info: info}, nil
+}
+
+
+// zipEntry is a compressed entry of a zip archive, it is decompressed while it's read, so it's never kept in memory.
+// Seeking is supported for range requests: seeking forward skips the decompressed data,
+// seeking backward decompresses the entry again from the beginning.
+type zipEntry struct {
+    entry   *zip.File
+    info    fs.FileInfo
+    rc      io.ReadCloser   // decompressor, nil if it is not opened
+    pos     int64           // position of rc
+    offset  int64           // position of the next Read, set by Seek
+}
+
+
+func (this *zipEntry) Stat() (fs.FileInfo, error) {
+    return this.info, nil
+}
+
+
+func (this *zipEntry) Read(b []byte) (int, error) {
+    if this.rc == nil || this.offset < this.pos {
+        if this.rc != nil {
+            this.rc.Close()
+        }
+        rc, err := this.entry.Open()
+        if err != nil {
+            return 0, err
+        }
+        this.rc, this.pos = rc, 0
+    }
+
+    if this.offset > this.pos {
+        n, err := io.CopyN(io.Discard, this.rc, this.offset - this.pos)
+        this.pos += n
+        if err != nil {
+            return 0, err
+        }
+    }
+
+    n, err := this.rc.Read(b)
+    this.pos += int64(n)
+    this.offset = this.pos
+    return n, err
+}
+
+
+// Seek only sets the position of the next Read, e.g. http.ServeContent seeks to the end to get the size.
+func (this *zipEntry) Seek(offset int64, whence int) (int64, error) {
+    switch whence {
+        case io.SeekStart:
+        case io.SeekCurrent:
+            offset += this.offset
+        case io.SeekEnd:
+            offset += this.info.Size()
+        default:
+            return 0, errors.New("Seek: invalid whence")
+    }
+    if offset < 0 {
+        return 0, errors.New("Seek: negative position")
+    }
+    this.offset = offset
+    return offset, nil
+}
+
+
+func (this *zipEntry) Close() error {
+    if this.rc != nil {
+        return this.rc.Close()
+    }
+    return nil
+}
+
+
+// tarFS is a read-only fs.FS of the entries of a tar archive.
+// Directories which are not in the archive are synthesised from the paths of the entries.
+type tarFS struct {
+    entries map[string]*tarEntry
+}
+
+
+type tarEntry struct {
+    info        fs.FileInfo
+    data        io.ReaderAt     // content of a regular file, nil for a directory
+    children    []fs.DirEntry   // entries of a directory, sorted by name
+}
+
+
+// dirInfo is the fs.FileInfo of a synthesised directory.
+type dirInfo struct {
+    name    string
+    modTime time.Time
+}
+
+func (this dirInfo) Name() string       { return this.name }
+func (this dirInfo) Size() int64        { return 0 }
+func (this dirInfo) Mode() fs.FileMode  { return fs.ModeDir | 0555 }
+func (this dirInfo) ModTime() time.Time { return this.modTime }
+func (this dirInfo) IsDir() bool        { return true }
+func (this dirInfo) Sys() interface{}   { return nil }
+
+
+// countingReader counts bytes read, so that offsets of the entries in a tar file are known.
+type countingReader struct {
+    r io.Reader
+    n int64
+}
+
+
+func (this *countingReader) Read(b []byte) (int, error) {
+    n, err := this.r.Read(b)
+    this.n += int64(n)
+    return n, err
+}
+
+
+// newTarFS reads the headers of a tar archive from r.
+// If file is not nil, r is the content of file, regular files are read from file by their offsets,
+// otherwise their content are read into memory.
+// modTime is used as the modification time of the synthesised directories.
+func newTarFS(r io.Reader, file io.ReaderAt, modTime time.Time) (*tarFS, error) {
+    this := &tarFS{entries: make(map[string]*tarEntry)}
+    this.entries["."] = &tarEntry{info: dirInfo{name: ".", modTime: modTime}}
+
+    counter := &countingReader{r: r}
+    tr := tar.NewReader(counter)
+
+    for {
+        hdr, err := tr.Next()
+        if err == io.EOF {
+            break
+        }
+        if err != nil {
+            return nil, err
+        }
+
+        name := path.Clean(strings.TrimPrefix(hdr.Name, "/"))
+        if name == "." || !fs.ValidPath(name) {
+            continue
+        }
+
+        entry := &tarEntry{info: hdr.FileInfo()}
+
+        switch hdr.Typeflag {
+            case tar.TypeDir:
+
+            case tar.TypeReg:
+                if file != nil && !isSparse(hdr) {
+                    entry.data = io.NewSectionReader(file, counter.n, hdr.Size)
+                    break
+                }
+                b, err := io.ReadAll(tr)
+                if err != nil {
+                    return nil, fmt.Errorf("Read '%s' error: %s", hdr.Name, err)
+                }
+                entry.data = bytes.NewReader(b)
+
+            // links and special files are not served
+            default:
+                continue
+        }
+
+        this.entries[name] = entry
+
+        // synthesise parent directories
+        for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+            if _, ok := this.entries[dir]; ok {
+                break
+            }
+            this.entries[dir] = &tarEntry{info: dirInfo{name: path.Base(dir), modTime: modTime}}
+        }
+    }
+
+    for name, entry := range this.entries {
+        if name == "." {
+            continue
+        }
+        parent := this.entries[path.Dir(name)]
+        // a file and a directory have the same name, the file is kept and the directory is not reachable
+        if !parent.info.IsDir() {
+            continue
+        }
+        parent.children = append(parent.children, fs.FileInfoToDirEntry(entry.info))
+    }
+    for _, entry := range this.entries {
+        sort.Slice(entry.children, func(i, j int) bool {
+            return entry.children[i].Name() < entry.children[j].Name()
+        })
+    }
+
+    return this, nil
+}
+
+
+// check if the content of a tar entry is not stored continuously
+func isSparse(hdr *tar.Header) bool {
+    if hdr.Typeflag == tar.TypeGNUSparse {
+        return true
+    }
+    for key := range hdr.PAXRecords {
+        if strings.HasPrefix(key, "GNU.sparse.") {
+            return true
+        }
+    }
+    return false
+}
+
+
+func (this *tarFS) Open(name string) (fs.File, error) {
+    if !fs.ValidPath(name) {
+        return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+    }
+
+    entry, ok := this.entries[name]
+    if !ok {
+        return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+    }
+
+    if entry.info.IsDir() {
+        return &tarDir{name: name, entry: entry}, nil
+    }
+    return &archiveFile{SectionReader: io.NewSectionReader(entry.data, 0, entry.info.Size()), info: entry.info}, nil
+}
+
+
+// archiveFile is a regular file of a tar or zip archive, it is seekable so that range requests are supported.
+type archiveFile struct {
+    *io.SectionReader
+    info fs.FileInfo
+}
+
+func (this *archiveFile) Stat() (fs.FileInfo, error) { return this.info, nil }
+func (this *archiveFile) Close() error               { return nil }
+
+
+// tarDir is a directory of a tar archive.
+type tarDir struct {
+    name    string
+    entry   *tarEntry
+    offset  int
+}
+
+func (this *tarDir) Stat() (fs.FileInfo, error) { return this.entry.info, nil }
+func (this *tarDir) Close() error               { return nil }
+
+
+func (this *tarDir) Read([]byte) (int, error) {
+    return 0, &fs.PathError{Op: "read", Path: this.name, Err: errors.New("is a directory")}
+}
+
+
+func (this *tarDir) ReadDir(n int) ([]fs.DirEntry, error) {
+    entries := this.entry.children[this.offset:]
+    if n > 0 {
+        if len(entries) == 0 {
+            return nil, io.EOF
+        }
+        if n < len(entries) {
+            entries = entries[:n]
+        }
+    }
+    this.offset += len(entries)
+    return entries, nil
+}
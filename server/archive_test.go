@@ -0,0 +1,203 @@
+package server
+
+import "archive/tar"
+import "archive/zip"
+import "bytes"
+import "io"
+import "io/fs"
+import "os"
+import "path/filepath"
+import "testing"
+import "time"
+
+
+type archiveEntry struct {
+    name    string
+    body    string  // a name ends with "/" is a directory
+}
+
+
+func makeTar(t *testing.T, entries []archiveEntry) []byte {
+    var buf bytes.Buffer
+    tw := tar.NewWriter(&buf)
+    for _, e := range entries {
+        hdr := &tar.Header{Name: e.name, Mode: 0644, Size: int64(len(e.body)), Typeflag: tar.TypeReg}
+        if e.name[len(e.name) - 1] == '/' {
+            hdr.Mode, hdr.Typeflag = 0755, tar.TypeDir
+        }
+        if err := tw.WriteHeader(hdr); err != nil {
+            t.Fatal(err)
+        }
+        if _, err := tw.Write([]byte(e.body)); err != nil {
+            t.Fatal(err)
+        }
+    }
+    if err := tw.Close(); err != nil {
+        t.Fatal(err)
+    }
+    return buf.Bytes()
+}
+
+
+func TestTarFS(t *testing.T) {
+    data := makeTar(t, []archiveEntry{
+        {"index.html", "<h1>home</h1>"},
+        {"/docs/guide/intro.md", "intro"},
+        {"docs/readme.md", "readme"},
+        {"assets/", ""},
+        {"assets/app.js", "app"},
+        {"clash", "file"},
+        {"clash/inner.txt", "inner"},
+        {"../escape.txt", "escape"},
+    })
+    modTime := time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+
+    // read by offsets, and read into memory like a .tar.gz
+    for _, file := range []io.ReaderAt{bytes.NewReader(data), nil} {
+        fsys, err := newTarFS(bytes.NewReader(data), file, modTime)
+        if err != nil {
+            t.Fatal(err)
+        }
+
+        files := []struct {
+            name    string
+            body    string
+        }{
+            {"index.html", "<h1>home</h1>"},
+            {"docs/guide/intro.md", "intro"},
+            {"docs/readme.md", "readme"},
+            {"assets/app.js", "app"},
+            {"clash", "file"},
+        }
+        for _, f := range files {
+            b, err := fs.ReadFile(fsys, f.name)
+            if err != nil || string(b) != f.body {
+                t.Errorf("ReadFile(%q) = %q, %v, want %q", f.name, b, err, f.body)
+            }
+        }
+
+        dirs := []struct {
+            name        string
+            children    []string
+        }{
+            {".", []string{"assets", "clash", "docs", "index.html"}},
+            {"docs", []string{"guide", "readme.md"}},   // synthesised
+            {"docs/guide", []string{"intro.md"}},       // synthesised
+            {"assets", []string{"app.js"}},
+        }
+        for _, d := range dirs {
+            info, err := fs.Stat(fsys, d.name)
+            if err != nil || !info.IsDir() {
+                t.Errorf("Stat(%q) should be a directory, error: %v", d.name, err)
+                continue
+            }
+            entries, err := fs.ReadDir(fsys, d.name)
+            if err != nil {
+                t.Errorf("ReadDir(%q): %s", d.name, err)
+                continue
+            }
+            var names []string
+            for _, entry := range entries {
+                names = append(names, entry.Name())
+            }
+            if len(names) != len(d.children) {
+                t.Errorf("ReadDir(%q) = %v, want %v", d.name, names, d.children)
+                continue
+            }
+            for i := range names {
+                if names[i] != d.children[i] {
+                    t.Errorf("ReadDir(%q) = %v, want %v", d.name, names, d.children)
+                    break
+                }
+            }
+        }
+
+        if info, _ := fs.Stat(fsys, "docs"); info != nil && !info.ModTime().Equal(modTime) {
+            t.Errorf("ModTime of a synthesised directory is %s, want %s", info.ModTime(), modTime)
+        }
+
+        // the file is kept, the directory of the same name is not reachable
+        if info, err := fs.Stat(fsys, "clash"); err != nil || info.IsDir() {
+            t.Errorf("Stat(clash) should be a file, error: %v", err)
+        }
+        if _, err := fs.ReadDir(fsys, "clash"); err == nil {
+            t.Errorf("ReadDir(clash) should fail")
+        }
+
+        for _, name := range []string{"escape.txt", "../escape.txt", "missing.txt"} {
+            if _, err := fsys.Open(name); err == nil {
+                t.Errorf("Open(%q) should fail", name)
+            }
+        }
+    }
+}
+
+
+func TestZipFSSeek(t *testing.T) {
+    body := bytes.Repeat([]byte("0123456789"), 1000)
+
+    var buf bytes.Buffer
+    zw := zip.NewWriter(&buf)
+    for _, method := range []struct {
+        name    string
+        method  uint16
+    }{
+        {"stored.txt", zip.Store},
+        {"deflated.txt", zip.Deflate},
+    } {
+        w, err := zw.CreateHeader(&zip.FileHeader{Name: method.name, Method: method.method})
+        if err != nil {
+            t.Fatal(err)
+        }
+        w.Write(body)
+    }
+    if _, err := zw.Create("dir/"); err != nil {
+        t.Fatal(err)
+    }
+    if err := zw.Close(); err != nil {
+        t.Fatal(err)
+    }
+
+    name := filepath.Join(t.TempDir(), "site.zip")
+    if err := os.WriteFile(name, buf.Bytes(), 0644); err != nil {
+        t.Fatal(err)
+    }
+    fsys, err := OpenArchive(name)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer fsys.(io.Closer).Close()
+
+    for _, name := range []string{"stored.txt", "deflated.txt"} {
+        f, err := fsys.Open(name)
+        if err != nil {
+            t.Fatal(err)
+        }
+        rs, ok := f.(io.ReadSeeker)
+        if !ok {
+            t.Fatalf("%s is not seekable", name)
+        }
+
+        // seek forward, backward, and to the end like http.ServeContent
+        for _, offset := range []int64{9995, 10, 9995} {
+            if size, err := rs.Seek(0, io.SeekEnd); err != nil || size != int64(len(body)) {
+                t.Fatalf("%s: size is %d, error: %v", name, size, err)
+            }
+            if _, err := rs.Seek(offset, io.SeekStart); err != nil {
+                t.Fatal(err)
+            }
+            b := make([]byte, 5)
+            if _, err := io.ReadFull(rs, b); err != nil || string(b) != string(body[offset:offset + 5]) {
+                t.Errorf("%s: read %q after seeking to %d, error: %v", name, b, offset, err)
+            }
+        }
+        if b, err := io.ReadAll(rs); err != nil || len(b) != 0 {
+            t.Errorf("%s: read %q at the end, error: %v", name, b, err)
+        }
+        f.Close()
+    }
+
+    if entries, err := fs.ReadDir(fsys, "."); err != nil || len(entries) != 3 {
+        t.Errorf("ReadDir(.) = %v, %v", entries, err)
+    }
+}
@@ -0,0 +1,196 @@
+package server
+
+import "fmt"
+import "net/http"
+import "path"
+import "regexp"
+import "strconv"
+import "strings"
+
+
+// Cache-Control value for files which will never change, e.g. files with a content hash in the name.
+const CacheImmutable = "public, max-age=31536000, immutable"
+
+
+/*
+CacheRule describes a Cache-Control policy for a group of files.
+
+Pattern could be one of the following forms:
+
+    .html           File extension, case insensitive.
+    /assets/        URL path prefix, must start and end with "/".
+    /js/*.js        Pattern matched against the whole URL path, see path.Match().
+    *.min.js        Pattern matched against the file name, see path.Match().
+
+Value is the value of Cache-Control header.
+*/
+type CacheRule struct {
+    Pattern string
+    Value   string
+}
+
+
+// match checks if a clean URL path matches the pattern of the rule.
+func (this *CacheRule) match(p string) bool {
+    if strings.HasPrefix(this.Pattern, ".") {
+        return strings.EqualFold(path.Ext(p), this.Pattern)
+    }
+
+    if strings.HasPrefix(this.Pattern, "/") {
+        if strings.HasSuffix(this.Pattern, "/") {
+            return strings.HasPrefix(p, this.Pattern)
+        }
+        ok, _ := path.Match(this.Pattern, p)
+        return ok
+    }
+
+    ok, _ := path.Match(this.Pattern, path.Base(p))
+    return ok
+}
+
+
+// CachePolicy is a list of cache rules, the first matched rule takes effect.
+type CachePolicy []CacheRule
+
+
+func (this *CachePolicy) String() string {
+    var s []string
+    for _, rule := range *this {
+        s = append(s, fmt.Sprintf("%s=%s", rule.Pattern, rule.Value))
+    }
+    return strings.Join(s, "; ")
+}
+
+
+/*
+Set parses a value like "<pattern>[,<pattern>...]=<value>" and appends rules to the policy.
+It could be called more than once, so a command-line flag of CachePolicy could be repeated.
+
+Value could be a Cache-Control header value or one of the following shortcuts:
+
+    immutable       Same as CacheImmutable.
+    <n><unit>       Cache for a period of time, unit could be s, m, h, d, w or y. Example: 12h, 7d, 1y.
+                    It is converted to "public, max-age=<seconds>".
+*/
+func (this *CachePolicy) Set(value string) error {
+    pair := strings.SplitN(value, "=", 2)
+    if len(pair) != 2 {
+        return fmt.Errorf("Cache rule should be in the form of <pattern>=<value>, got '%s'", value)
+    }
+
+    cacheControl, err := parseCacheValue(pair[1])
+    if err != nil {
+        return err
+    }
+
+    var patterns []string
+    for _, p := range strings.Split(pair[0], ",") {
+        p = strings.TrimSpace(p)
+        if p == "" {
+            continue
+        }
+        if _, err := path.Match(p, ""); err != nil {
+            return fmt.Errorf("Invalid cache rule pattern '%s'", p)
+        }
+        patterns = append(patterns, p)
+    }
+    if len(patterns) == 0 {
+        return fmt.Errorf("Cache rule pattern cannot be empty, got '%s'", value)
+    }
+
+    for _, p := range patterns {
+        *this = append(*this, CacheRule{Pattern: p, Value: cacheControl})
+    }
+    return nil
+}
+
+
+// Find the Cache-Control value for a clean URL path, return empty string if no rule matches.
+func (this CachePolicy) lookup(p string) string {
+    for _, rule := range this {
+        if rule.match(p) {
+            return rule.Value
+        }
+    }
+    return ""
+}
+
+
+var cacheUnits = map[byte]int64 {
+    's': 1,
+    'm': 60,
+    'h': 3600,
+    'd': 86400,
+    'w': 86400 * 7,
+    'y': 86400 * 365,
+}
+
+
+// convert a shortcut of cache rule value to a Cache-Control header value.
+func parseCacheValue(value string) (string, error) {
+    value = strings.TrimSpace(value)
+    if value == "" {
+        return "", fmt.Errorf("Value of cache rule cannot be empty")
+    }
+
+    if strings.ToLower(value) == "immutable" {
+        return CacheImmutable, nil
+    }
+
+    if len(value) > 1 {
+        if unit, ok := cacheUnits[value[len(value) - 1]]; ok {
+            if n, err := strconv.ParseInt(value[:len(value) - 1], 10, 64); err == nil && n >= 0 {
+                return fmt.Sprintf("public, max-age=%d", n * unit), nil
+            }
+        }
+    }
+
+    return value, nil
+}
+
+
+// File names with a content hash, e.g. app.3f2a9c1b.js, main-5d41402abc4b2a76.css, index-BXk3fq2a.js
+var hashedNamePattern = regexp.MustCompile(`[.-]([0-9a-zA-Z_]{8,})\.[0-9a-zA-Z]+$`)
+var hexPattern = regexp.MustCompile(`^[0-9a-f]+$`)
+
+
+// lengths of hex hashes generated by the bundlers, e.g. 8 (vite), 20 (webpack) and 32 (md5)
+var hexHashLengths = map[int]bool{8: true, 10: true, 16: true, 20: true, 32: true}
+
+
+// isHashedName checks if a file name contains a content hash generated by bundlers like webpack or vite.
+func isHashedName(name string) bool {
+    // ignore the extra extension like .min.js
+    name = strings.Replace(name, ".min.", ".", 1)
+
+    m := hashedNamePattern.FindStringSubmatch(name)
+    if m == nil {
+        return false
+    }
+    hash := m[1]
+
+    hasDigit  := strings.ContainsAny(hash, "0123456789")
+    hasLower  := strings.ContainsAny(hash, "abcdefghijklmnopqrstuvwxyz")
+    hasUpper  := strings.ContainsAny(hash, "ABCDEFGHIJKLMNOPQRSTUVWXYZ")
+
+    // hex hash, like 3f2a9c1b. digits only are not a hash, e.g. the date of report-20261019.pdf
+    if hexPattern.MatchString(hash) {
+        return hasDigit && hasLower && hexHashLengths[len(hash)]
+    }
+
+    // base64url hash, like BXk3fq2a
+    return len(hash) <= 16 && hasDigit && hasLower && hasUpper
+}
+
+
+// setCacheHeader writes a Cache-Control header according to the cache policy.
+// p is the clean URL path of the file to be served.
+func (this *RanServer) setCacheHeader(w http.ResponseWriter, p string) {
+    value := this.config.CachePolicy.lookup(p)
+    if value == "" && this.config.CacheHashed && isHashedName(path.Base(p)) {
+        value = CacheImmutable
+    }
+    if value != "" {
+        w.Header().Set("Cache-Control", value)
+    }
+}
@@ -0,0 +1,63 @@
+package server
+
+import "testing"
+
+
+func TestIsHashedName(t *testing.T) {
+    tests := []struct {
+        name    string
+        hashed  bool
+    }{
+        {"app.3f2a9c1b.js", true},
+        {"app.3f2a9c1b.min.js", true},
+        {"main-5d41402abc4b2a76.css", true},
+        {"vendor.0123456789abcdef0123.js", true},
+        {"index-BXk3fq2a.js", true},
+        {"index.js", false},
+        {"report-20261019.pdf", false},
+        {"backup.12345678.tar", false},
+        {"app.3f2a9c1b7.js", false},
+        {"app.abcdefab.js", false},
+        {"jquery-3.7.1.min.js", false},
+        {"read-me-first.txt", false},
+        {"document.pdf", false},
+    }
+
+    for _, test := range tests {
+        if got := isHashedName(test.name); got != test.hashed {
+            t.Errorf("isHashedName(%q) = %t, want %t", test.name, got, test.hashed)
+        }
+    }
+}
+
+
+func TestCachePolicyLookup(t *testing.T) {
+    var policy CachePolicy
+    for _, rule := range []string{"/assets/=1y", ".HTML=no-cache", "/js/*.js=1d", "*.min.css=immutable", ".css=1h"} {
+        if err := policy.Set(rule); err != nil {
+            t.Fatalf("Set(%q): %s", rule, err)
+        }
+    }
+
+    tests := []struct {
+        path    string
+        value   string
+    }{
+        {"/assets/logo.png", "public, max-age=31536000"},
+        {"/assets/index.html", "public, max-age=31536000"},
+        {"/index.html", "no-cache"},
+        {"/docs/INDEX.Html", "no-cache"},
+        {"/js/app.js", "public, max-age=86400"},
+        {"/js/lib/app.js", ""},
+        {"/css/site.min.css", CacheImmutable},
+        {"/css/site.css", "public, max-age=3600"},
+        {"/assets", ""},
+        {"/readme.txt", ""},
+    }
+
+    for _, test := range tests {
+        if got := policy.lookup(test.path); got != test.value {
+            t.Errorf("lookup(%q) = %q, want %q", test.path, got, test.value)
+        }
+    }
+}
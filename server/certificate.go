@@ -0,0 +1,300 @@
+package server
+
+import "crypto/tls"
+import "crypto/x509"
+import "errors"
+import "fmt"
+import "os"
+import "path/filepath"
+import "sort"
+import "strings"
+import "sync"
+import "time"
+
+
+// CertLoader loads a certificate and a private key from files, and reloads them when the files are changed.
+// Use CertLoader.GetCertificate in tls.Config, so that a renewed certificate takes effect without a restart.
+type CertLoader struct {
+    certFile    string
+    keyFile     string
+    logger      Logger
+    mu          sync.RWMutex
+    cert        *tls.Certificate
+    certMod     time.Time   // modification time of the loaded certificate file
+    keyMod      time.Time   // modification time of the loaded private key file
+}
+
+
+// NewCertLoader loads the certificate and the private key, return an error if they are not valid.
+func NewCertLoader(certFile, keyFile string, logger Logger) (*CertLoader, error) {
+    loader := &CertLoader {
+        certFile:   certFile,
+        keyFile:    keyFile,
+        logger:     logger,
+    }
+    if err := loader.Reload(); err != nil {
+        return nil, err
+    }
+    return loader, nil
+}
+
+
+func (this *CertLoader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+    this.mu.RLock()
+    defer this.mu.RUnlock()
+    return this.cert, nil
+}
+
+
+// Certificate returns the certificate currently in use.
+func (this *CertLoader) Certificate() *tls.Certificate {
+    cert, _ := this.GetCertificate(nil)
+    return cert
+}
+
+
+// loadCertificate loads and validates a certificate and it's private key.
+func loadCertificate(certFile, keyFile string) (*tls.Certificate, error) {
+    cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+    if err != nil {
+        return nil, err
+    }
+
+    cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0])
+    if err != nil {
+        return nil, err
+    }
+
+    now := time.Now()
+    if now.Before(cert.Leaf.NotBefore) {
+        return nil, fmt.Errorf("Certificate '%s' is not valid until %s", certFile, cert.Leaf.NotBefore)
+    }
+    if now.After(cert.Leaf.NotAfter) {
+        return nil, fmt.Errorf("Certificate '%s' has expired at %s", certFile, cert.Leaf.NotAfter)
+    }
+
+    return &cert, nil
+}
+
+
+func modTime(file string) (time.Time, error) {
+    info, err := os.Stat(file)
+    if err != nil {
+        return time.Time{}, err
+    }
+    return info.ModTime(), nil
+}
+
+
+// Reload loads the certificate and the private key from files.
+// If the new pair is not valid, the certificate in use is kept and an error is returned.
+func (this *CertLoader) Reload() error {
+    certMod, err := modTime(this.certFile)
+    if err != nil {
+        return err
+    }
+    keyMod, err := modTime(this.keyFile)
+    if err != nil {
+        return err
+    }
+
+    cert, err := loadCertificate(this.certFile, this.keyFile)
+    if err != nil {
+        return err
+    }
+
+    this.mu.Lock()
+    this.cert = cert
+    this.certMod = certMod
+    this.keyMod = keyMod
+    this.mu.Unlock()
+
+    this.logger.Infof("System: Certificate loaded: subject: %s, names: %s, expires at: %s",
+        cert.Leaf.Subject.String(), strings.Join(cert.Leaf.DNSNames, ", "),
+        cert.Leaf.NotAfter.Format("2006-01-02 15:04:05 MST"))
+    return nil
+}
+
+
+// changed checks if the certificate file or the private key file is modified after loading.
+func (this *CertLoader) changed() bool {
+    certMod, err := modTime(this.certFile)
+    if err != nil {
+        return false
+    }
+    keyMod, err := modTime(this.keyFile)
+    if err != nil {
+        return false
+    }
+
+    this.mu.RLock()
+    defer this.mu.RUnlock()
+    return !certMod.Equal(this.certMod) || !keyMod.Equal(this.keyMod)
+}
+
+
+// Watch checks the files every interval and reloads them when they are changed. It never returns.
+func (this *CertLoader) Watch(interval time.Duration) {
+    // the last error is recorded to avoid logging the same error repeatedly,
+    // e.g. the new certificate is written but the new private key is not.
+    var lastErr string
+
+    for range time.Tick(interval) {
+        if !this.changed() {
+            continue
+        }
+
+        err := this.Reload()
+        if err != nil {
+            if err.Error() != lastErr {
+                this.logger.Errorf("System: Reload certificate error, the old certificate is still in use: %s", err)
+                lastErr = err.Error()
+            }
+            continue
+        }
+        lastErr = ""
+    }
+}
+
+
+// CertPair is the paths of a certificate file and it's private key file.
+type CertPair struct {
+    Cert    string
+    Key     string
+}
+
+
+// CertPairs is a list of CertPair, it could be used as a repeatable command-line flag.
+type CertPairs []CertPair
+
+
+func (this *CertPairs) String() string {
+    var s []string
+    for _, pair := range *this {
+        s = append(s, pair.Cert + "," + pair.Key)
+    }
+    return strings.Join(s, "; ")
+}
+
+
+// Set parses a value like "<cert>,<key>", e.g. "example.com.crt,example.com.key".
+func (this *CertPairs) Set(value string) error {
+    pair := strings.SplitN(value, ",", 2)
+    if len(pair) != 2 || strings.TrimSpace(pair[0]) == "" || strings.TrimSpace(pair[1]) == "" {
+        return fmt.Errorf("Certificate pair should be in the form of <cert>,<key>, got '%s'", value)
+    }
+    *this = append(*this, CertPair{Cert: strings.TrimSpace(pair[0]), Key: strings.TrimSpace(pair[1])})
+    return nil
+}
+
+
+// certificate file extensions of a directory of certificates, in order of priority
+var certExts = []string{".crt", ".pem", ".cer"}
+
+
+// CertDirPairs finds the certificates in a directory, a <name>.key file and a <name>.crt (or .pem, .cer) file are a pair.
+// Pairs are sorted by file name.
+func CertDirPairs(dir string) (pairs CertPairs, err error) {
+    keys, err := filepath.Glob(filepath.Join(dir, "*.key"))
+    if err != nil {
+        return
+    }
+    sort.Strings(keys)
+
+    for _, key := range keys {
+        name := strings.TrimSuffix(key, ".key")
+        for _, ext := range certExts {
+            if info, e := os.Stat(name + ext); e == nil && !info.IsDir() {
+                pairs = append(pairs, CertPair{Cert: name + ext, Key: key})
+                break
+            }
+        }
+    }
+
+    if len(pairs) == 0 {
+        err = fmt.Errorf("No certificate and private key pair found in '%s'", dir)
+    }
+    return
+}
+
+
+// CertLoaders selects a certificate by the server name (SNI) of the TLS handshake,
+// the first certificate is the default, it's used if no certificate matches the server name.
+type CertLoaders []*CertLoader
+
+
+// NewCertLoaders loads the certificates and private keys, return an error if any of them are not valid.
+func NewCertLoaders(pairs []CertPair, logger Logger) (CertLoaders, error) {
+    if len(pairs) == 0 {
+        return nil, errors.New("No certificate is provided")
+    }
+
+    var loaders CertLoaders
+    for _, pair := range pairs {
+        loader, err := NewCertLoader(pair.Cert, pair.Key, logger)
+        if err != nil {
+            return nil, fmt.Errorf("Load certificate '%s' error: %s", pair.Cert, err)
+        }
+        loaders = append(loaders, loader)
+    }
+    return loaders, nil
+}
+
+
+// GetCertificate returns the first certificate which supports the client, e.g. the server name and the signature
+// algorithms, the default certificate is returned if there is not one.
+func (this CertLoaders) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+    if hello != nil && hello.ServerName != "" && len(this) > 1 {
+        for _, loader := range this {
+            cert := loader.Certificate()
+            if hello.SupportsCertificate(cert) == nil {
+                return cert, nil
+            }
+        }
+    }
+    return this[0].GetCertificate(hello)
+}
+
+
+// Certificate returns the default certificate.
+func (this CertLoaders) Certificate() *tls.Certificate {
+    return this[0].Certificate()
+}
+
+
+// Match checks if a host name is in one of the certificates, a wildcard host like *.example.com
+// matches a wildcard certificate of the same name.
+func (this CertLoaders) Match(host string) bool {
+    for _, loader := range this {
+        cert := loader.Certificate()
+        if cert.Leaf != nil && cert.Leaf.VerifyHostname(host) == nil {
+            return true
+        }
+    }
+    return false
+}
+
+
+// Reload loads all the certificates and private keys from files, invalid pairs are not changed.
+func (this CertLoaders) Reload() error {
+    var errs []string
+    for _, loader := range this {
+        if err := loader.Reload(); err != nil {
+            errs = append(errs, fmt.Sprintf("'%s': %s", loader.certFile, err))
+        }
+    }
+    if len(errs) > 0 {
+        return errors.New(strings.Join(errs, "; "))
+    }
+    return nil
+}
+
+
+// Watch checks the files of all the certificates every interval and reloads them when they are changed.
+// It never returns.
+func (this CertLoaders) Watch(interval time.Duration) {
+    for _, loader := range this[1:] {
+        go loader.Watch(interval)
+    }
+    this[0].Watch(interval)
+}
@@ -0,0 +1,47 @@
+package server
+
+import "net/http"
+import "path"
+import "strings"
+
+
+// clientCertUser returns common name of the verified client certificate, or empty string if there is none.
+func clientCertUser(r *http.Request) string {
+    if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+        return ""
+    }
+    return r.TLS.VerifiedChains[0][0].Subject.CommonName
+}
+
+
+// check if a request path needs a client certificate
+func (this *ClientCert) protects(p string) bool {
+    if len(this.Paths) == 0 {
+        return true
+    }
+
+    // the path is not cleaned before serveHTTP(), e.g. /public/../admin
+    p = path.Clean("/" + p)
+
+    for _, prefix := range this.Paths {
+        prefix = strings.TrimSuffix(prefix, "/")
+        if prefix == "" || p == prefix || strings.HasPrefix(p, prefix + "/") {
+            return true
+        }
+    }
+    return false
+}
+
+
+// clientCertHandler returns 403 Forbidden if a protected path is requested without a verified client certificate.
+func (this *RanServer) clientCertHandler(fn http.HandlerFunc) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        if this.config.ClientCert.protects(r.URL.Path) && clientCertUser(r) == "" {
+            this.logger.Debugf("#%s: Client certificate required", w.Header().Get("X-Request-Id"))
+            this.metrics.authFailed("client_certificate")
+            ErrorEx(w, http.StatusForbidden, "", "<h1>403 Forbidden</h1><p>A valid client certificate is required.</p>")
+            return
+        }
+        fn(w, r)
+    }
+}
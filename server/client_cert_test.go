@@ -0,0 +1,57 @@
+package server
+
+import "crypto/tls"
+import "crypto/x509"
+import "crypto/x509/pkix"
+import "net/http/httptest"
+import "testing"
+
+
+func TestClientCertUser(t *testing.T) {
+    r := httptest.NewRequest("GET", "/", nil)
+    if user := clientCertUser(r); user != "" {
+        t.Errorf("Plain HTTP: user is %q, want empty", user)
+    }
+
+    r.TLS = &tls.ConnectionState{}
+    if user := clientCertUser(r); user != "" {
+        t.Errorf("No verified chain: user is %q, want empty", user)
+    }
+
+    cert := &x509.Certificate{Subject: pkix.Name{CommonName: "alice"}}
+    r.TLS.VerifiedChains = [][]*x509.Certificate{{cert}}
+    if user := clientCertUser(r); user != "alice" {
+        t.Errorf("Verified chain: user is %q, want \"alice\"", user)
+    }
+}
+
+
+func TestClientCertProtects(t *testing.T) {
+    all := &ClientCert{}
+    if !all.protects("/anything") {
+        t.Error("All paths should be protected if Paths is empty")
+    }
+
+    c := &ClientCert{Paths: []string{"/admin", "/private/"}}
+    tests := []struct {
+        path        string
+        protected   bool
+    }{
+        {"/admin", true},
+        {"/admin/", true},
+        {"/admin/users", true},
+        {"/private/file.txt", true},
+        {"/private", true},
+        {"/public/../admin/users", true},
+        {"//admin", true},
+        {"/administrator", false},
+        {"/", false},
+        {"/public/file.txt", false},
+    }
+
+    for _, test := range tests {
+        if got := c.protects(test.path); got != test.protected {
+            t.Errorf("protects(%q) = %t, want %t", test.path, got, test.protected)
+        }
+    }
+}
@@ -35,15 +35,25 @@ type Auth struct {
 }
 
 
-// ErrorFilePath describe path of a 401/404 file which is under directory of Root.
+// ClientCert contains options of client certificate authentication in the handler.
+// Client certificates are verified during the TLS handshake, the handler checks if a verified certificate is given.
+type ClientCert struct {
+    // paths which require a verified client certificate, relative to "/".
+    // if Paths is empty, all paths are protected.
+    Paths []string
+}
+
+
+// ErrorFilePath describe path of a 401/404/503 file which is under directory of Root.
 type ErrorFilePath struct {
     Abs string // Absolute path of error file, e.g. /data/wwwroot/404.html
-    Rel string // Path of error file, relative to the root, e.g. /404.html
+    Rel string // Path of error file, relative to the root, e.g. /404.html. RanServer reads the file by Rel.
 }
 
 
 type Config struct {
     Root        string          // Root path of the website. Default is current working directory.
+                                // A RanServer created by New reads files from it's fs.FS instead.
     Path404     *ErrorFilePath  // Path of custom 404 file, under directory of Root.
                                 // When a 404 not found error occurs, the file's content will be send to client.
                                 // nil means do not use 404 file.
@@ -56,9 +66,24 @@ type Config struct {
                                 // Default is false.
     Gzip        bool            // If turn on gzip compression, default is true.
     NoCache     bool            // If true, ran will write some no-cache headers to the response. Default is false.
+                                // NoCache has higher priority than CachePolicy and CacheHashed.
+    CachePolicy CachePolicy     // Cache-Control rules matched by file extension or path pattern.
+    CacheHashed bool            // If true, files with a content hash in the name will be marked as immutable.
+                                // Rules in CachePolicy have higher priority. Default is false.
     CORS        bool            // If true, ran will write some CORS headers to the response. Default is false.
     Auth        *Auth           // If not nil, turn on authentication.
+    ClientCert  *ClientCert     // If not nil, requests need a verified client certificate.
+    HSTS        *HSTS           // If not nil, send the Strict-Transport-Security header on HTTPS responses.
     ServeAll    bool            // If is false, path start with dot will not be served, that means a 404 error will be returned.
+    RateLimit   *RateLimit      // If not nil, limit request rate and bandwidth of clients.
+    Proxy       *Proxy          // If not nil, forward requests under the prefixes of routes to the backends.
+    Metrics     *MetricsOption  // If not nil, collect metrics of requests and connections.
+    Health      *Health         // If not nil, serve the health endpoints.
+    Maintenance *MaintenanceOption // Options of maintenance mode, nil means default options.
+    LiveReload  *LiveReloadOption  // If not nil, browsers reload the pages when files under Root are changed.
+    Header      Header          // Headers set to the responses of the site.
+    VirtualHosts VirtualHosts   // Sites served by host names, requests of other hosts are served by this Config.
+    Mounts      Mounts          // Separate roots served under URL prefixes, they are shown as directories in the listing.
 }
 
 
@@ -1,9 +1,9 @@
 package server
 
+import "errors"
 import "net/http"
-import "os"
+import "io/fs"
 import "path"
-import "path/filepath"
 import "strings"
 import "fmt"
 import "net/url"
@@ -13,7 +13,7 @@ import "net/url"
 type context struct {
     cleanPath       string  // clean path relative to root
     url             string  // cleanPath + query string (used to do 307 redirect if r.url is not clean)
-    absFilePath     string  // absolute path pointing to a file or dir of the disk
+    name            string  // name of the file or dir in the fs.FS of the site, e.g. css/site.css
     exist           bool
     isDir           bool
     indexPath       string  // if path is a directory, detect index path
@@ -24,13 +24,30 @@ type context struct {
 
 // String() is used for log output
 func (c *context) String() string {
-    return fmt.Sprintf("cleanPath: %s, url: %s, absFilePath: %s, exist: %t, isDir: %t, indexPath: %s",
-        c.cleanPath, c.url, c.absFilePath, c.exist, c.isDir, c.indexPath)
+    return fmt.Sprintf("cleanPath: %s, url: %s, name: %s, exist: %t, isDir: %t, indexPath: %s",
+        c.cleanPath, c.url, c.name, c.exist, c.isDir, c.indexPath)
 }
 
 
-// Make a new context
-func newContext(config Config, r *http.Request) (c *context, err error) {
+// fsName converts a clean path start with "/" to a name of fs.FS, e.g. /css/site.css to css/site.css, / to .
+func fsName(p string) string {
+    name := strings.Trim(p, "/")
+    if name == "" {
+        return "."
+    }
+    return name
+}
+
+
+// check if an error of fs.Stat means the file does not exist.
+// a name which is not valid in the fs.FS, e.g. contains a colon on Windows, does not exist either.
+func isNotExist(err error) bool {
+    return errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrInvalid)
+}
+
+
+// Make a new context, files are looked up in fsys.
+func newContext(config Config, fsys fs.FS, r *http.Request) (c *context, err error) {
     c = new(context)
 
     requestPath := r.URL.Path
@@ -43,14 +60,11 @@ func newContext(config Config, r *http.Request) (c *context, err error) {
     }
     c.cleanPath = path.Clean(requestPath)
 
-    c.absFilePath, err = filepath.Abs(filepath.Join(config.Root, c.cleanPath))
-    if err != nil {
-        return
-    }
+    c.name = fsName(c.cleanPath)
 
-    info, e := os.Stat(c.absFilePath)
+    info, e := fs.Stat(fsys, c.name)
     if e != nil {
-        if os.IsNotExist(e) {
+        if isNotExist(e) {
             c.exist = false
         } else {
             err = e
@@ -70,10 +84,10 @@ func newContext(config Config, r *http.Request) (c *context, err error) {
 
     if c.isDir {
         for _, name := range config.IndexName {
-            index := filepath.Join(c.absFilePath, name)
-            indexInfo, e := os.Stat(index)
+            index := path.Join(c.name, name)
+            indexInfo, e := fs.Stat(fsys, index)
             if e != nil {
-                if os.IsNotExist(e) {
+                if isNotExist(e) {
                     continue
                 } else {
                     err = e
@@ -86,10 +100,7 @@ func newContext(config Config, r *http.Request) (c *context, err error) {
             } else {
                 c.isDir = false
                 c.indexPath = path.Join(c.cleanPath, name)
-                c.absFilePath, err = filepath.Abs(filepath.Join(config.Root, c.indexPath))
-                if err != nil {
-                    return
-                }
+                c.name = index
                 // add trailing slash if the request path is a directory and the directory contains a index file
                 if !strings.HasSuffix(c.cleanPath, "/") {
                     c.cleanPath += "/"
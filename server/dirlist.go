@@ -8,7 +8,7 @@ import "net/http"
 import "net/url"
 import "html"
 import "path"
-import "path/filepath"
+import "io/fs"
 import "strings"
 
 
@@ -92,6 +92,11 @@ var tplDirList *template.Template
 
 
 func timeToString(t time.Time, format ...string) string {
+    // directories synthesised from the entries of an archive may have no modification time
+    if t.IsZero() {
+        return ""
+    }
+
     f := "2006-01-02 15:04:05"
     if len(format) > 0 && format[0] != "" {
         f = format[0]
@@ -125,13 +130,7 @@ func (this *RanServer) listDir(w http.ResponseWriter, serveAll bool, c *context)
         return
     }
 
-    f, err := os.Open(c.absFilePath)
-    if err != nil {
-        return
-    }
-    defer f.Close()
-
-    info, err := f.Readdir(0)
+    entries, err := fs.ReadDir(this.fsys, c.name)
     if err != nil {
         return
     }
@@ -142,7 +141,13 @@ func (this *RanServer) listDir(w http.ResponseWriter, serveAll bool, c *context)
 
     var files []dirListFiles
 
-    for n, i := range info {
+    for n, entry := range entries {
+        var i fs.FileInfo
+        i, err = entry.Info()
+        if err != nil {
+            return
+        }
+
         name := i.Name()
         if i.IsDir() {
             name += "/"
@@ -166,8 +171,8 @@ func (this *RanServer) listDir(w http.ResponseWriter, serveAll bool, c *context)
                 return
             }
 
-            var info os.FileInfo
-            info, err = os.Stat(filepath.Join(this.config.Root, parentUnescape))
+            var info fs.FileInfo
+            info, err = fs.Stat(this.fsys, fsName(parentUnescape))
             if err != nil {
                 return
             }
@@ -3,6 +3,7 @@ package server
 import "net/http"
 import "fmt"
 import "io/ioutil"
+import "io/fs"
 import "path"
 import hhelper "github.com/m3ng9i/go-utils/http"
 
@@ -43,38 +44,64 @@ func Error(w http.ResponseWriter, code int) int64 {
 // ErrorFile404 writes 404 file to client.
 // abspath is path of 404 file.
 func ErrorFile404(w http.ResponseWriter, abspath string) (int64, error) {
+    return ErrorFile(w, 404, abspath)
+}
+
+
+// ErrorFile writes an error file to client with the status code.
+// abspath is path of the error file.
+func ErrorFile(w http.ResponseWriter, code int, abspath string) (int64, error) {
 
     b, err := ioutil.ReadFile(abspath)
     if err != nil {
         return 0, err
     }
 
-    contentType, _ := hhelper.FileContentType(path.Ext(abspath))
+    return writeErrorFile(w, code, abspath, b), nil
+}
+
+
+// errorFile writes a custom error file of the site to client with the status code.
+func (this *RanServer) errorFile(w http.ResponseWriter, code int, p *ErrorFilePath) (int64, error) {
+
+    b, err := fs.ReadFile(this.fsys, fsName(p.Rel))
+    if err != nil {
+        return 0, err
+    }
+
+    return writeErrorFile(w, code, p.Rel, b), nil
+}
+
+
+// errorFileContentType returns content type of an error file by it's extension, default is html.
+func errorFileContentType(name string) string {
+    contentType, _ := hhelper.FileContentType(path.Ext(name))
     if contentType == "" {
         contentType = "text/html; charset=utf-8"
     }
-    w.Header().Set("Content-Type", contentType)
-    w.WriteHeader(404)
-    n, _ := w.Write(b)
-    return int64(n), nil
+    return contentType
 }
 
 
-func errorFile401(config Config) (a *hhelper.AuthFile, err error) {
-    if config.Path401 != nil {
-        tp, _ := hhelper.FileContentType(path.Ext(config.Path401.Abs))
-        if tp == "" {
-            tp = "text/html; charset=utf-8"
-        }
+// writeErrorFile writes content of an error file, name is used to detect the content type.
+func writeErrorFile(w http.ResponseWriter, code int, name string, b []byte) int64 {
+    w.Header().Set("Content-Type", errorFileContentType(name))
+    w.WriteHeader(code)
+    n, _ := w.Write(b)
+    return int64(n)
+}
+
 
-        b, e := ioutil.ReadFile(config.Path401.Abs)
+func (this *RanServer) errorFile401() (a *hhelper.AuthFile, err error) {
+    if this.config.Path401 != nil {
+        b, e := fs.ReadFile(this.fsys, fsName(this.config.Path401.Rel))
         if e != nil {
             err = e
             return
         }
 
         a = new(hhelper.AuthFile)
-        a.ContentType = tp
+        a.ContentType = errorFileContentType(this.config.Path401.Rel)
         a.Body = b
 
         return
@@ -0,0 +1,109 @@
+package server
+
+import "crypto/tls"
+import "encoding/json"
+import "fmt"
+import "io"
+import "net/http"
+import "io/fs"
+import "time"
+
+
+// VersionInfo is returned by the version endpoint, values are injected by build.py.
+type VersionInfo struct {
+    Version     string  `json:"version"`
+    Branch      string  `json:"branch"`
+    CommitId    string  `json:"commit_id"`
+    BuildTime   string  `json:"build_time"`
+}
+
+
+// Health contains options of the health endpoints:
+// <prefix>/live (liveness), <prefix>/ready (readiness) and <prefix>/version.
+// The endpoints are not protected by authentication, client certificates and rate limits.
+type Health struct {
+    Prefix      string      // Path prefix of the endpoints, e.g. /_ran.
+    NoLog       bool        // If true, requests of the endpoints are not written to the access log.
+    Version     VersionInfo
+}
+
+
+type healthStatus struct {
+    Status  string      `json:"status"`
+    Errors  []string    `json:"errors,omitempty"`
+}
+
+
+// SetCertificate sets a function which returns the certificate in use,
+// the readiness endpoint checks if the certificate is expired.
+func (this *RanServer) SetCertificate(f func() *tls.Certificate) {
+    this.certificate = f
+}
+
+
+// ready checks if the root directory is readable, maintenance mode is off and the certificate is not expired.
+func (this *RanServer) ready() (errs []string) {
+    f, err := this.fsys.Open(".")
+    if err == nil {
+        if dir, ok := f.(fs.ReadDirFile); ok {
+            _, err = dir.ReadDir(1)
+            if err == io.EOF {
+                err = nil
+            }
+        }
+        f.Close()
+    }
+    if err != nil {
+        errs = append(errs, fmt.Sprintf("Root is not readable: %s", err))
+    }
+
+    if this.Maintenance() {
+        errs = append(errs, "Maintenance mode is on")
+    }
+
+    if this.certificate != nil {
+        cert := this.certificate()
+        if cert == nil || cert.Leaf == nil {
+            errs = append(errs, "No certificate is loaded")
+        } else if time.Now().After(cert.Leaf.NotAfter) {
+            errs = append(errs, fmt.Sprintf("Certificate has expired at %s", cert.Leaf.NotAfter))
+        }
+    }
+
+    return
+}
+
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+    b, _ := json.Marshal(v)
+    setNoCacheHeader(w)
+    w.Header().Set("Content-Type", "application/json; charset=utf-8")
+    w.WriteHeader(code)
+    w.Write(b)
+}
+
+
+// healthHandler serves the health endpoints, other requests are sent to fn.
+func (this *RanServer) healthHandler(fn http.HandlerFunc) http.HandlerFunc {
+    prefix := this.config.Health.Prefix
+
+    return func(w http.ResponseWriter, r *http.Request) {
+        switch r.URL.Path {
+            case prefix + "/live":
+                writeJSON(w, http.StatusOK, healthStatus{Status: "ok"})
+
+            case prefix + "/ready":
+                if errs := this.ready(); len(errs) > 0 {
+                    writeJSON(w, http.StatusServiceUnavailable, healthStatus{Status: "fail", Errors: errs})
+                } else {
+                    writeJSON(w, http.StatusOK, healthStatus{Status: "ok"})
+                }
+
+            case prefix + "/version":
+                writeJSON(w, http.StatusOK, this.config.Health.Version)
+
+            default:
+                fn(w, r)
+        }
+    }
+}
@@ -0,0 +1,69 @@
+package server
+
+import "fmt"
+import "net/http"
+import "strconv"
+import "strings"
+
+
+// MaxAge is a number of seconds, it could be set by a string like 3600, 12h, 180d or 1y.
+type MaxAge int64
+
+
+func (this *MaxAge) String() string {
+    return strconv.FormatInt(int64(*this), 10)
+}
+
+
+func (this *MaxAge) Set(value string) error {
+    s := strings.TrimSpace(value)
+
+    var unit int64 = 1
+    if len(s) > 1 {
+        if u, ok := cacheUnits[s[len(s) - 1]]; ok {
+            unit = u
+            s = s[:len(s) - 1]
+        }
+    }
+
+    n, err := strconv.ParseInt(s, 10, 64)
+    if err != nil || n < 0 {
+        return fmt.Errorf("Invalid max age '%s'", value)
+    }
+    *this = MaxAge(n * unit)
+    return nil
+}
+
+
+// HSTSPreloadMinAge is the minimum max-age required by the HSTS preload list.
+const HSTSPreloadMinAge MaxAge = 86400 * 365
+
+
+// HSTS contains options of the Strict-Transport-Security header.
+// The header is only sent on HTTPS responses, and HTTP requests are redirected with 301 instead of 307.
+type HSTS struct {
+    MaxAge              MaxAge  // How long browsers should only visit the site by HTTPS.
+    IncludeSubDomains   bool    // If true, the rule also applies to all the subdomains.
+    Preload             bool    // If true, allow the site to be included in the HSTS preload list of browsers.
+}
+
+
+// String returns value of the Strict-Transport-Security header.
+func (this *HSTS) String() string {
+    s := fmt.Sprintf("max-age=%d", this.MaxAge)
+    if this.IncludeSubDomains {
+        s += "; includeSubDomains"
+    }
+    if this.Preload {
+        s += "; preload"
+    }
+    return s
+}
+
+
+// set the Strict-Transport-Security header if the request is sent over TLS.
+func (this *RanServer) setHSTSHeader(w http.ResponseWriter, r *http.Request) {
+    if this.config.HSTS != nil && r.TLS != nil {
+        w.Header().Set("Strict-Transport-Security", this.config.HSTS.String())
+    }
+}
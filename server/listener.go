@@ -0,0 +1,154 @@
+package server
+
+import "net"
+import "sync"
+import "sync/atomic"
+import "time"
+
+
+// rejectedLogInterval is the interval of logging the number of rejected connections,
+// so that a flood of connections does not flood the log.
+var rejectedLogInterval = time.Minute
+
+
+// ConnLimiter limits the number of simultaneous connections per client IP and in total.
+// A ConnLimiter could be shared by several listeners, so the limits apply to all of them.
+type ConnLimiter struct {
+    maxConns        int                 // Max number of connections in total. 0 means no limit.
+    maxConnsPerIP   int                 // Max number of connections per client IP. 0 means no limit.
+    logger          Logger
+    mu              sync.Mutex
+    total           int
+    perIP           map[string]int
+    rejected        uint64
+    logOnce         sync.Once
+}
+
+
+func NewConnLimiter(maxConns, maxConnsPerIP int, logger Logger) *ConnLimiter {
+    return &ConnLimiter {
+        maxConns:       maxConns,
+        maxConnsPerIP:  maxConnsPerIP,
+        logger:         logger,
+        perIP:          make(map[string]int),
+    }
+}
+
+
+// Rejected returns number of connections rejected by the limiter.
+func (this *ConnLimiter) Rejected() uint64 {
+    return atomic.LoadUint64(&this.rejected)
+}
+
+
+// logRejected logs the number of connections rejected in every interval, nothing is logged if there is none.
+func (this *ConnLimiter) logRejected(interval time.Duration) {
+    var last uint64
+    for range time.Tick(interval) {
+        n := this.Rejected()
+        if n > last {
+            this.logger.Infof("System: %d connections rejected by the connection limits in the last %s (total: %d)",
+                n - last, interval, n)
+        }
+        last = n
+    }
+}
+
+
+// acquire a connection slot for ip, return a reason if the limit is reached.
+// if ip is empty, only the total limit is checked.
+func (this *ConnLimiter) acquire(ip string) (reason string) {
+    this.mu.Lock()
+    defer this.mu.Unlock()
+
+    if this.maxConns > 0 && this.total >= this.maxConns {
+        return "total connection limit reached"
+    }
+    if ip != "" && this.maxConnsPerIP > 0 && this.perIP[ip] >= this.maxConnsPerIP {
+        return "per-IP connection limit reached"
+    }
+
+    this.total++
+    if ip != "" {
+        this.perIP[ip]++
+    }
+    return ""
+}
+
+
+func (this *ConnLimiter) release(ip string) {
+    this.mu.Lock()
+    defer this.mu.Unlock()
+
+    this.total--
+    if ip == "" {
+        return
+    }
+    this.perIP[ip]--
+    if this.perIP[ip] <= 0 {
+        delete(this.perIP, ip)
+    }
+}
+
+
+// Listener wraps a net.Listener, connections exceeding the limits are closed right after they are accepted.
+func (this *ConnLimiter) Listener(l net.Listener) net.Listener {
+    if this.maxConns <= 0 && this.maxConnsPerIP <= 0 {
+        return l
+    }
+    this.logOnce.Do(func() {
+        go this.logRejected(rejectedLogInterval)
+    })
+    return &limitListener{Listener: l, limiter: this}
+}
+
+
+type limitListener struct {
+    net.Listener
+    limiter *ConnLimiter
+}
+
+
+func (this *limitListener) Accept() (net.Conn, error) {
+    for {
+        c, err := this.Listener.Accept()
+        if err != nil {
+            return nil, err
+        }
+
+        // connections of Unix domain sockets have no client IP, only the total limit applies to them
+        var ip string
+        if c.RemoteAddr().Network() != "unix" {
+            ip, _, err = net.SplitHostPort(c.RemoteAddr().String())
+            if err != nil {
+                ip = c.RemoteAddr().String()
+            }
+        }
+
+        reason := this.limiter.acquire(ip)
+        if reason == "" {
+            return &limitConn{Conn: c, ip: ip, limiter: this.limiter}, nil
+        }
+
+        atomic.AddUint64(&this.limiter.rejected, 1)
+        c.Close()
+        this.limiter.logger.Debugf("System: Connection from %s to %s rejected: %s", ip, this.Addr().String(), reason)
+    }
+}
+
+
+type limitConn struct {
+    net.Conn
+    ip          string
+    limiter     *ConnLimiter
+    releaseOnce sync.Once
+}
+
+
+func (this *limitConn) Close() error {
+    err := this.Conn.Close()
+    this.releaseOnce.Do(func() {
+        this.limiter.release(this.ip)
+    })
+    return err
+}
@@ -0,0 +1,108 @@
+package server
+
+import "net"
+import "strings"
+import "testing"
+import "time"
+import "github.com/m3ng9i/go-utils/log"
+
+
+// chanWriter sends the log messages to a channel.
+type chanWriter chan string
+
+
+func (this chanWriter) Write(b []byte) (int, error) {
+    this <- string(b)
+    return len(b), nil
+}
+
+
+func TestConnLimiter(t *testing.T) {
+    interval := rejectedLogInterval
+    rejectedLogInterval = 50 * time.Millisecond
+    defer func() {
+        rejectedLogInterval = interval
+    }()
+
+    messages := make(chanWriter, 10)
+    var config log.Config
+    config.Layout       = log.LY_DEFAULT
+    config.LayoutStyle  = log.LS_DEFAULT
+    config.TimeFormat   = log.TF_DEFAULT
+    config.Level        = log.INFO
+    logger, err := log.New(messages, config)
+    if err != nil {
+        t.Fatal(err)
+    }
+    limiter := NewConnLimiter(0, 1, logger)
+
+    l, err := net.Listen("tcp", "127.0.0.1:0")
+    if err != nil {
+        t.Fatal(err)
+    }
+    l = limiter.Listener(l)
+    defer l.Close()
+
+    accepted := make(chan net.Conn, 10)
+    go func() {
+        for {
+            c, err := l.Accept()
+            if err != nil {
+                return
+            }
+            accepted <- c
+        }
+    }()
+
+    first, err := net.Dial("tcp", l.Addr().String())
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer first.Close()
+    conn := <-accepted
+
+    // the second connection of the same IP is closed by the listener
+    for i := 0; i < 2; i++ {
+        c, err := net.Dial("tcp", l.Addr().String())
+        if err != nil {
+            t.Fatal(err)
+        }
+        c.SetReadDeadline(time.Now().Add(time.Second))
+        if _, err := c.Read(make([]byte, 1)); err == nil {
+            t.Error("A connection over the per-IP limit should be closed")
+        }
+        c.Close()
+    }
+    if n := limiter.Rejected(); n != 2 {
+        t.Errorf("Rejected() = %d, want 2", n)
+    }
+
+    // rejected connections are logged once per interval
+    select {
+        case msg := <-messages:
+            if !strings.Contains(msg, "2 connections rejected") {
+                t.Errorf("Unexpected log message: %s", msg)
+            }
+        case <-time.After(time.Second):
+            t.Fatal("Rejected connections are not logged")
+    }
+    select {
+        case msg := <-messages:
+            t.Errorf("Nothing should be logged if no connection is rejected: %s", msg)
+        case <-time.After(3 * rejectedLogInterval):
+    }
+
+    // a slot is released after the connection is closed
+    conn.Close()
+    second, err := net.Dial("tcp", l.Addr().String())
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer second.Close()
+    select {
+        case c := <-accepted:
+            c.Close()
+        case <-time.After(time.Second):
+            t.Error("A connection should be accepted after the first one is closed")
+    }
+}
@@ -0,0 +1,382 @@
+package server
+
+import "bytes"
+import "errors"
+import "fmt"
+import "net/http"
+import "os"
+import "path"
+import "path/filepath"
+import "sort"
+import "strings"
+import "sync"
+import "time"
+import "github.com/fsnotify/fsnotify"
+
+
+// LiveReloadPath is the URL path of the Server-Sent Events stream of live reload.
+const LiveReloadPath = "/.ran/livereload"
+
+
+// changes in this period are sent to browsers at once, editors usually write several events when saving a file.
+const liveReloadDelay = 100 * time.Millisecond
+
+
+// interval of sending a comment to keep the event stream open
+const liveReloadPing = 30 * time.Second
+
+
+// script injected into HTML responses. A change of CSS files reloads the stylesheets only,
+// other changes reload the whole page.
+var liveReloadScript = []byte(`<script>
+(function() {
+    var es = new EventSource("` + LiveReloadPath + `");
+    es.addEventListener("reload", function() {
+        location.reload();
+    });
+    es.addEventListener("css", function() {
+        var links = document.querySelectorAll('link[rel="stylesheet"]');
+        for (var i = 0; i < links.length; i++) {
+            var url = new URL(links[i].href);
+            url.searchParams.set("livereload", Date.now());
+            links[i].href = url.href;
+        }
+    });
+})();
+</script>
+`)
+
+
+// Patterns is a list of patterns separated by comma, so that it could be used as a repeatable command-line flag.
+type Patterns []string
+
+
+func (this *Patterns) String() string {
+    return strings.Join(*this, ",")
+}
+
+
+func (this *Patterns) Set(value string) error {
+    for _, p := range strings.Split(value, ",") {
+        p = strings.TrimSpace(p)
+        if p != "" {
+            *this = append(*this, p)
+        }
+    }
+    return nil
+}
+
+
+// LiveReloadOption contains options of live reload.
+type LiveReloadOption struct {
+    // Paths which are not watched. A pattern starts with "/" is a path relative to the root, e.g. /build,
+    // other patterns are matched against every element of a path, e.g. node_modules, *.tmp.
+    // Paths start with dot are not watched unless ServeAll is true.
+    Ignore Patterns
+}
+
+
+// liveReload watches the root directory and tells the browsers to reload when files are changed.
+type liveReload struct {
+    root        string
+    option      *LiveReloadOption
+    serveAll    bool
+    logger      Logger
+    watcher     *fsnotify.Watcher
+
+    mu          sync.Mutex
+    clients     map[chan string]bool
+}
+
+
+// StartLiveReload watches the root directory recursively, HTML responses are injected with a script
+// which reloads the page when files are changed.
+func (this *RanServer) StartLiveReload() error {
+    if this.config.Root == "" {
+        return errors.New("Live reload needs Root of the config to watch")
+    }
+
+    watcher, err := fsnotify.NewWatcher()
+    if err != nil {
+        return err
+    }
+
+    option := this.config.LiveReload
+    if option == nil {
+        option = new(LiveReloadOption)
+    }
+
+    lr := &liveReload {
+        root:       this.config.Root,
+        option:     option,
+        serveAll:   this.config.ServeAll,
+        logger:     this.logger,
+        watcher:    watcher,
+        clients:    make(map[chan string]bool),
+    }
+
+    if err = lr.addDir(lr.root); err != nil {
+        watcher.Close()
+        return fmt.Errorf("Watch '%s' error: %s", lr.root, err)
+    }
+
+    go lr.watch()
+
+    this.liveReload = lr
+    return nil
+}
+
+
+// rel returns the path relative to the root in URL form, e.g. /css/site.css
+func (this *liveReload) rel(p string) string {
+    rel, err := filepath.Rel(this.root, p)
+    if err != nil {
+        return p
+    }
+    return "/" + filepath.ToSlash(rel)
+}
+
+
+// ignored checks if a path is not watched.
+func (this *liveReload) ignored(p string) bool {
+    rel := this.rel(p)
+    names := strings.Split(strings.Trim(rel, "/"), "/")
+
+    if !this.serveAll {
+        for _, name := range names {
+            if strings.HasPrefix(name, ".") {
+                return true
+            }
+        }
+    }
+
+    for _, pattern := range this.option.Ignore {
+        if strings.HasPrefix(pattern, "/") {
+            prefix := strings.TrimSuffix(pattern, "/")
+            if rel == prefix || strings.HasPrefix(rel, prefix + "/") {
+                return true
+            }
+            continue
+        }
+        for _, name := range names {
+            if ok, _ := path.Match(pattern, name); ok {
+                return true
+            }
+        }
+    }
+
+    return false
+}
+
+
+// addDir watches a directory and it's sub directories, ignored directories are skipped.
+func (this *liveReload) addDir(dir string) error {
+    return filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+        if err != nil {
+            return err
+        }
+        if !info.IsDir() {
+            return nil
+        }
+        if p != this.root && this.ignored(p) {
+            return filepath.SkipDir
+        }
+        return this.watcher.Add(p)
+    })
+}
+
+
+func (this *liveReload) watch() {
+    changed := make(map[string]bool)
+    var timer <-chan time.Time
+
+    for {
+        select {
+            case event, ok := <-this.watcher.Events:
+                if !ok {
+                    return
+                }
+                if event.Op == fsnotify.Chmod || this.ignored(event.Name) {
+                    continue
+                }
+
+                // watch new directories, e.g. created by a build tool
+                if event.Has(fsnotify.Create) {
+                    if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+                        if err = this.addDir(event.Name); err != nil {
+                            this.logger.Errorf("System: Live reload: Watch '%s' error: %s", event.Name, err)
+                        }
+                    }
+                }
+
+                changed[this.rel(event.Name)] = true
+                if timer == nil {
+                    timer = time.After(liveReloadDelay)
+                }
+
+            case err, ok := <-this.watcher.Errors:
+                if !ok {
+                    return
+                }
+                this.logger.Errorf("System: Live reload: %s", err)
+
+            case <-timer:
+                this.broadcast(changed)
+                changed = make(map[string]bool)
+                timer = nil
+        }
+    }
+}
+
+
+// broadcast sends a "css" event to the browsers if only CSS files are changed, otherwise sends a "reload" event.
+func (this *liveReload) broadcast(changed map[string]bool) {
+    event := "css"
+    var paths []string
+    for p := range changed {
+        paths = append(paths, p)
+        if strings.ToLower(path.Ext(p)) != ".css" {
+            event = "reload"
+        }
+    }
+    sort.Strings(paths)
+
+    this.mu.Lock()
+    defer this.mu.Unlock()
+
+    this.logger.Infof("System: Live reload: Changed: %s, %d browser(s) notified (%s)",
+        strings.Join(paths, ", "), len(this.clients), event)
+
+    for c := range this.clients {
+        select {
+            case c <- event:
+            default:
+                // the last event is not received yet, replace it with a reload which covers both
+                select {
+                    case <-c:
+                    default:
+                }
+                c <- "reload"
+        }
+    }
+}
+
+
+// ServeHTTP sends the change events to a browser by Server-Sent Events.
+func (this *liveReload) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+    flusher, ok := w.(http.Flusher)
+    if !ok {
+        Error(w, http.StatusInternalServerError)
+        return
+    }
+
+    c := make(chan string, 1)
+    this.mu.Lock()
+    this.clients[c] = true
+    this.mu.Unlock()
+
+    defer func() {
+        this.mu.Lock()
+        delete(this.clients, c)
+        this.mu.Unlock()
+    }()
+
+    setNoCacheHeader(w)
+    w.Header().Set("Content-Type", "text/event-stream")
+    w.WriteHeader(http.StatusOK)
+
+    // browsers reconnect after 1 second if ran is restarted
+    fmt.Fprint(w, "retry: 1000\n\n")
+    flusher.Flush()
+
+    ticker := time.NewTicker(liveReloadPing)
+    defer ticker.Stop()
+
+    for {
+        select {
+            case event := <-c:
+                fmt.Fprintf(w, "event: %s\ndata: \n\n", event)
+            case <-ticker.C:
+                fmt.Fprint(w, ": ping\n\n")
+            case <-r.Context().Done():
+                return
+        }
+        flusher.Flush()
+    }
+}
+
+
+// liveReloadHandler serves the event stream of live reload, other requests are sent to fn.
+func (this *RanServer) liveReloadHandler(fn http.HandlerFunc) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        if r.URL.Path == LiveReloadPath {
+            this.liveReload.ServeHTTP(w, r)
+            return
+        }
+        fn(w, r)
+    }
+}
+
+
+// scriptInjector buffers HTML responses and inserts the live reload script before </body>.
+type scriptInjector struct {
+    http.ResponseWriter
+    buf         *bytes.Buffer   // nil if the response is not modified
+    wroteHeader bool
+    head        bool            // if the request is HEAD, headers are the same as GET but the script is not written
+}
+
+
+func (this *scriptInjector) WriteHeader(code int) {
+    if this.wroteHeader {
+        return
+    }
+    this.wroteHeader = true
+
+    // Content-Encoding is not checked, it is set by the gzip handler which compresses the injected response
+    header := this.Header()
+    if code == http.StatusOK && strings.HasPrefix(header.Get("Content-Type"), "text/html") {
+        this.buf = new(bytes.Buffer)
+        header.Del("Content-Length")
+    }
+    this.ResponseWriter.WriteHeader(code)
+}
+
+
+func (this *scriptInjector) Write(b []byte) (int, error) {
+    if !this.wroteHeader {
+        this.WriteHeader(http.StatusOK)
+    }
+    if this.buf != nil {
+        return this.buf.Write(b)
+    }
+    return this.ResponseWriter.Write(b)
+}
+
+
+// finish writes the buffered HTML with the script.
+func (this *scriptInjector) finish() {
+    if this.buf == nil || this.head {
+        return
+    }
+
+    body := this.buf.Bytes()
+    i := bytes.LastIndex(bytes.ToLower(body), []byte("</body>"))
+    if i < 0 {
+        i = len(body)
+    }
+
+    this.ResponseWriter.Write(body[:i])
+    this.ResponseWriter.Write(liveReloadScript)
+    this.ResponseWriter.Write(body[i:])
+}
+
+
+// injectScriptHandler injects the live reload script into HTML responses of fn.
+func injectScriptHandler(fn http.HandlerFunc) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        injector := &scriptInjector{ResponseWriter: w, head: r.Method == http.MethodHead}
+        fn(injector, r)
+        injector.finish()
+    }
+}
@@ -0,0 +1,69 @@
+package server
+
+import "bytes"
+import "net/http"
+import "net/http/httptest"
+import "strings"
+import "testing"
+
+
+func TestInjectScript(t *testing.T) {
+    script := string(liveReloadScript)
+
+    tests := []struct {
+        method      string
+        contentType string
+        code        int
+        body        string
+        want        string
+    }{
+        {"GET", "text/html; charset=utf-8", 200, "<html><body><p>hi</p></body></html>",
+            "<html><body><p>hi</p>" + script + "</body></html>"},
+        {"GET", "text/html", 200, "<HTML><BODY>hi</BODY></HTML>", "<HTML><BODY>hi" + script + "</BODY></HTML>"},
+        {"GET", "text/html", 200, "<p>no body tag</p>", "<p>no body tag</p>" + script},
+        {"GET", "text/css", 200, "body { color: red; }", "body { color: red; }"},
+        {"GET", "text/html", 404, "<html><body>Not found</body></html>", "<html><body>Not found</body></html>"},
+        {"HEAD", "text/html", 200, "", ""},
+    }
+
+    for _, test := range tests {
+        handler := injectScriptHandler(func(w http.ResponseWriter, r *http.Request) {
+            w.Header().Set("Content-Type", test.contentType)
+            w.Header().Set("Content-Length", "1000")
+            w.WriteHeader(test.code)
+            w.Write([]byte(test.body))
+        })
+
+        w := httptest.NewRecorder()
+        handler(w, httptest.NewRequest(test.method, "/", nil))
+
+        if w.Code != test.code {
+            t.Errorf("%s %s: status is %d, want %d", test.method, test.contentType, w.Code, test.code)
+        }
+        if got := w.Body.String(); got != test.want {
+            t.Errorf("%s %s (%d): body is %q, want %q", test.method, test.contentType, test.code, got, test.want)
+        }
+
+        // the length is changed if the script is injected
+        injected := strings.HasPrefix(test.contentType, "text/html") && test.code == 200
+        if got := w.Header().Get("Content-Length") == ""; got != injected {
+            t.Errorf("%s %s (%d): Content-Length is %q", test.method, test.contentType, test.code,
+                w.Header().Get("Content-Length"))
+        }
+    }
+}
+
+
+func TestInjectScriptImplicitHeader(t *testing.T) {
+    handler := injectScriptHandler(func(w http.ResponseWriter, r *http.Request) {
+        w.Header().Set("Content-Type", "text/html")
+        w.Write([]byte("<body>"))
+        w.Write([]byte("hi</body>"))
+    })
+
+    w := httptest.NewRecorder()
+    handler(w, httptest.NewRequest("GET", "/", nil))
+    if !bytes.Equal(w.Body.Bytes(), []byte("<body>hi" + string(liveReloadScript) + "</body>")) {
+        t.Errorf("Body is %q", w.Body.String())
+    }
+}
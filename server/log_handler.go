@@ -1,7 +1,9 @@
 package server
 
+import "bufio"
 import "strconv"
 import "errors"
+import "net"
 import "fmt"
 import "strings"
 import "net/http"
@@ -23,6 +25,50 @@ func (this Header) String() string {
 }
 
 
+// Set adds a header in the form of "Name: value", so that a Header could be used as a repeatable command-line flag.
+func (this Header) Set(value string) error {
+    pair := strings.SplitN(value, ":", 2)
+    if len(pair) != 2 || strings.TrimSpace(pair[0]) == "" {
+        return fmt.Errorf("Header should be in the form of <name>: <value>, got '%s'", value)
+    }
+    http.Header(this).Add(strings.TrimSpace(pair[0]), strings.TrimSpace(pair[1]))
+    return nil
+}
+
+
+// responseSniffer is a hhelper.ResponseSniffer which supports flushing and hijacking of the underlying
+// ResponseWriter, so that streaming responses and protocol upgrades (e.g. WebSocket) could be proxied.
+type responseSniffer struct {
+    *hhelper.ResponseSniffer
+    rw http.ResponseWriter
+}
+
+
+func newResponseSniffer(w http.ResponseWriter) *responseSniffer {
+    return &responseSniffer{ResponseSniffer: hhelper.NewSniffer(w, false), rw: w}
+}
+
+
+func (this *responseSniffer) Flush() {
+    if f, ok := this.rw.(http.Flusher); ok {
+        f.Flush()
+    }
+}
+
+
+func (this *responseSniffer) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+    h, ok := this.rw.(http.Hijacker)
+    if !ok {
+        return nil, nil, errors.New("The ResponseWriter does not support hijacking")
+    }
+    conn, rw, err := h.Hijack()
+    if err == nil {
+        this.Code = http.StatusSwitchingProtocols
+    }
+    return conn, rw, err
+}
+
+
 var ErrInvalidLogLayout = errors.New("Invalid log layout")
 
 /*
@@ -33,8 +79,9 @@ Below are format specifiers and there meanings:
 %%  Percent sign (%)
 %i  Request id
 %s  Response status code
-%h  Host
+%h  Host, and the matched virtual host if any
 %a  Client ip address
+%U  User name (common name of the verified client certificate)
 %m  Request method
 %l  Request url
 %r  Referer
@@ -43,14 +90,15 @@ Below are format specifiers and there meanings:
 %t  Response time
 %c  Compression status (gzip / none)
 %S  Scheme (http or https)
+%P  Protocol (HTTP/1.1, HTTP/2.0 or HTTP/3.0)
 */
 type LogLayout string
 
 
-var LogLayoutNormal LogLayout = `Access #%i: [Status: %s] [Host: %h] [IP: %a] [Method: %m] [Scheme: %S] [URL: %l] [Referer: %r] [UA: %u] [Size: %n] [Time: %t] [Compression: %c]`
+var LogLayoutNormal LogLayout = `Access #%i: [Status: %s] [Host: %h] [IP: %a] [User: %U] [Method: %m] [Scheme: %S] [Protocol: %P] [URL: %l] [Referer: %r] [UA: %u] [Size: %n] [Time: %t] [Compression: %c]`
 
 
-var LogLayoutShort LogLayout = `Access #%i: [%s] [%h] [%a] [%m] [%S] [%l] [%r] [%u] [%n] [%t] [%c]`
+var LogLayoutShort LogLayout = `Access #%i: [%s] [%h] [%a] [%U] [%m] [%S] [%P] [%l] [%r] [%u] [%n] [%t] [%c]`
 
 
 var LogLayoutMin LogLayout = `Access #%i: [%s] [%a] [%m] [%l] [%n]`
@@ -64,7 +112,7 @@ func (this *LogLayout) IsLegal() bool {
     OUTER:
     for _, c := range *this {
         if in {
-            for _, ch := range []rune("%ishamlruntcS") {
+            for _, ch := range []rune("%ishaUmlruntcSP") {
                 if c == ch {
                     in = false
                     continue OUTER
@@ -82,7 +130,7 @@ func (this *LogLayout) IsLegal() bool {
 }
 
 
-func (this *RanServer) accessLog(sniffer *hhelper.ResponseSniffer, r *http.Request, responseTime int64) error {
+func (this *RanServer) accessLog(sniffer *responseSniffer, r *http.Request, responseTime int64) error {
 
     buf := bufferPool.Get()
     defer bufferPool.Put(buf)
@@ -106,6 +154,10 @@ func (this *RanServer) accessLog(sniffer *hhelper.ResponseSniffer, r *http.Reque
                 // host
                 case 'h':
                     buf.WriteString(r.Host)
+                    // no space in the value, so the fields could be split by spaces
+                    if _, name := matchVirtualHost(this.config.VirtualHosts, r.Host); name != "" {
+                        buf.WriteString("(vhost:" + name + ")")
+                    }
 
                 // client ip address
                 case 'a':
@@ -116,6 +168,10 @@ func (this *RanServer) accessLog(sniffer *hhelper.ResponseSniffer, r *http.Reque
                     }
                     buf.WriteString(ip)
 
+                // user name
+                case 'U':
+                    buf.WriteString(clientCertUser(r))
+
                 // request method
                 case 'm':
                     buf.WriteString(r.Method)
@@ -160,6 +216,10 @@ func (this *RanServer) accessLog(sniffer *hhelper.ResponseSniffer, r *http.Reque
                         buf.WriteString("http")
                     }
 
+                // protocol
+                case 'P':
+                    buf.WriteString(r.Proto)
+
                 default:
                     return ErrInvalidLogLayout
             }
@@ -184,7 +244,16 @@ func (this *RanServer) logHandler(fn http.HandlerFunc) http.HandlerFunc {
     return func(w http.ResponseWriter, r *http.Request) {
         startTime := time.Now()
 
-        sniffer := hhelper.NewSniffer(w, false)
+        end := this.metrics.begin()
+        defer end()
+
+        // bytes written before gzip compression are counted for the metrics
+        var uncompressed uint64
+        if this.metrics != nil {
+            r = withByteCounter(r, &uncompressed)
+        }
+
+        sniffer := newResponseSniffer(w)
 
         fn(sniffer, r)
 
@@ -194,6 +263,8 @@ func (this *RanServer) logHandler(fn http.HandlerFunc) http.HandlerFunc {
 
         responseTime := time.Since(startTime).Nanoseconds()
 
+        this.metrics.observe(sniffer, r, responseTime, uncompressed)
+
         err := this.accessLog(sniffer, r, responseTime)
         if err != nil {
             this.logger.Errorf("#%s: accessLog(): %s", requestId, err)
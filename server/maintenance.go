@@ -0,0 +1,163 @@
+package server
+
+import "fmt"
+import "net"
+import "net/http"
+import "os"
+import "strconv"
+import "strings"
+import "sync/atomic"
+import "time"
+import hhelper "github.com/m3ng9i/go-utils/http"
+
+
+// IPNets is a list of IP addresses and networks, so that it could be used as a command-line flag.
+type IPNets []*net.IPNet
+
+
+func (this *IPNets) String() string {
+    var s []string
+    for _, n := range *this {
+        s = append(s, n.String())
+    }
+    return strings.Join(s, ", ")
+}
+
+
+// Set adds IP addresses or networks in CIDR notation, separate by comma, e.g. "10.0.0.1,192.168.1.0/24".
+func (this *IPNets) Set(value string) error {
+    for _, item := range strings.Split(value, ",") {
+        item = strings.TrimSpace(item)
+        if item == "" {
+            continue
+        }
+
+        if !strings.Contains(item, "/") {
+            ip := net.ParseIP(item)
+            if ip == nil {
+                return fmt.Errorf("Invalid IP address '%s'", item)
+            }
+            bits := 8 * net.IPv6len
+            if ip.To4() != nil {
+                ip = ip.To4()
+                bits = 8 * net.IPv4len
+            }
+            *this = append(*this, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+            continue
+        }
+
+        _, n, err := net.ParseCIDR(item)
+        if err != nil {
+            return fmt.Errorf("Invalid network '%s'", item)
+        }
+        *this = append(*this, n)
+    }
+    return nil
+}
+
+
+// Contains checks if an IP address is in the list.
+func (this IPNets) Contains(ip string) bool {
+    addr := net.ParseIP(ip)
+    if addr == nil {
+        return false
+    }
+    for _, n := range this {
+        if n.Contains(addr) {
+            return true
+        }
+    }
+    return false
+}
+
+
+// MaintenanceOption contains options of maintenance mode.
+type MaintenanceOption struct {
+    On          bool            // If true, maintenance mode is on at startup.
+    RetryAfter  time.Duration   // Value of the Retry-After header. 0 means the header is not sent.
+    Path503     *ErrorFilePath  // Path of custom 503 file, under directory of Root. nil means do not use 503 file.
+    File        string          // Absolute path of a marker file, maintenance mode is on while the file exists.
+                                // The file is checked every second.
+                                // Empty means no marker file.
+    Allow       IPNets          // Client IPs which bypass maintenance mode.
+}
+
+
+// maintenanceFileInterval is the interval of checking if the marker file exists.
+var maintenanceFileInterval = time.Second
+
+
+// maintenanceState is shared by a RanServer and the RanServer made by SetConfig.
+type maintenanceState struct {
+    on      int32   // 1 if maintenance mode is turned on by SetMaintenance
+    file    int32   // 1 if the marker file exists
+}
+
+
+// SetMaintenance turns on or off maintenance mode, requests get 503 Service Unavailable in maintenance mode.
+// Maintenance mode is still on if the marker file exists.
+func (this *RanServer) SetMaintenance(on bool) {
+    var v int32
+    if on {
+        v = 1
+    }
+    atomic.StoreInt32(&this.maintenance.on, v)
+}
+
+
+// Maintenance reports if maintenance mode is on, by SetMaintenance or the marker file.
+func (this *RanServer) Maintenance() bool {
+    return atomic.LoadInt32(&this.maintenance.on) == 1 || atomic.LoadInt32(&this.maintenance.file) == 1
+}
+
+
+// watchMaintenanceFile checks if the marker file exists on a ticker, so that requests need not check the file.
+func (this *RanServer) watchMaintenanceFile(file string) {
+    check := func() {
+        var v int32
+        if _, err := os.Stat(file); err == nil {
+            v = 1
+        }
+        atomic.StoreInt32(&this.maintenance.file, v)
+    }
+
+    check()
+    interval := maintenanceFileInterval
+    go func() {
+        for range time.Tick(interval) {
+            check()
+        }
+    }()
+}
+
+
+// maintenanceHandler returns 503 Service Unavailable if maintenance mode is on,
+// requests from the allowed IPs are sent to fn.
+func (this *RanServer) maintenanceHandler(fn http.HandlerFunc) http.HandlerFunc {
+    option := this.config.Maintenance
+    if option == nil {
+        option = new(MaintenanceOption)
+    }
+
+    return func(w http.ResponseWriter, r *http.Request) {
+        if !this.Maintenance() || option.Allow.Contains(hhelper.GetIP(r)) {
+            fn(w, r)
+            return
+        }
+
+        if option.RetryAfter > 0 {
+            w.Header().Set("Retry-After", strconv.Itoa(int(option.RetryAfter.Seconds())))
+        }
+        setNoCacheHeader(w)
+
+        if option.Path503 != nil {
+            _, err := this.errorFile(w, http.StatusServiceUnavailable, option.Path503)
+            if err == nil {
+                return
+            }
+            requestId := w.Header().Get("X-Request-Id")
+            this.logger.Errorf("#%s: Load 503 file error: %s", requestId, err)
+        }
+        Error(w, http.StatusServiceUnavailable)
+    }
+}
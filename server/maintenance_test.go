@@ -0,0 +1,133 @@
+package server
+
+import "net/http"
+import "net/http/httptest"
+import "os"
+import "path/filepath"
+import "testing"
+import "time"
+
+
+func TestIPNets(t *testing.T) {
+    var nets IPNets
+    if err := nets.Set("10.0.0.1, 192.168.1.0/24,::1"); err != nil {
+        t.Fatal(err)
+    }
+
+    tests := []struct {
+        ip          string
+        contains    bool
+    }{
+        {"10.0.0.1", true},
+        {"10.0.0.2", false},
+        {"192.168.1.200", true},
+        {"192.168.2.1", false},
+        {"::1", true},
+        {"invalid", false},
+    }
+
+    for _, test := range tests {
+        if got := nets.Contains(test.ip); got != test.contains {
+            t.Errorf("Contains(%q) = %t, want %t", test.ip, got, test.contains)
+        }
+    }
+
+    for _, value := range []string{"10.0.0", "10.0.0.0/33"} {
+        if err := new(IPNets).Set(value); err == nil {
+            t.Errorf("Set(%q) should return an error", value)
+        }
+    }
+}
+
+
+func TestMaintenanceHandler(t *testing.T) {
+    root := t.TempDir()
+    if err := os.WriteFile(filepath.Join(root, "a.txt"), []byte("hello"), 0644); err != nil {
+        t.Fatal(err)
+    }
+
+    var allow IPNets
+    if err := allow.Set("10.0.0.0/8"); err != nil {
+        t.Fatal(err)
+    }
+    c := Config {
+        Root:           root,
+        IndexName:      Index{"index.html"},
+        Maintenance:    &MaintenanceOption{On: true, RetryAfter: 5 * time.Minute, Allow: allow},
+    }
+    ran := NewRanServer(c, newTestLogger(t))
+    handler := ran.Serve()
+
+    request := func(ip string) *httptest.ResponseRecorder {
+        r := httptest.NewRequest("GET", "/a.txt", nil)
+        r.RemoteAddr = ip + ":50000"
+        w := httptest.NewRecorder()
+        handler(w, r)
+        return w
+    }
+
+    w := request("192.0.2.1")
+    if w.Code != http.StatusServiceUnavailable {
+        t.Fatalf("Status is %d, want 503", w.Code)
+    }
+    if v := w.Header().Get("Retry-After"); v != "300" {
+        t.Errorf("Retry-After is %q, want \"300\"", v)
+    }
+    if v := w.Header().Get("Cache-Control"); v == "" {
+        t.Error("Cache-Control should be set in maintenance mode")
+    }
+
+    if w := request("10.1.2.3"); w.Code != http.StatusOK {
+        t.Errorf("Status of an allowed IP is %d, want 200", w.Code)
+    }
+
+    ran.SetMaintenance(false)
+    if w := request("192.0.2.1"); w.Code != http.StatusOK || w.Header().Get("Retry-After") != "" {
+        t.Errorf("After maintenance: status is %d, Retry-After is %q", w.Code, w.Header().Get("Retry-After"))
+    }
+}
+
+
+func TestMaintenanceFile(t *testing.T) {
+    interval := maintenanceFileInterval
+    maintenanceFileInterval = 10 * time.Millisecond
+    defer func() {
+        maintenanceFileInterval = interval
+    }()
+
+    root := t.TempDir()
+    marker := filepath.Join(root, ".maintenance")
+    if err := os.WriteFile(marker, nil, 0644); err != nil {
+        t.Fatal(err)
+    }
+
+    c := Config {
+        Root:           root,
+        IndexName:      Index{"index.html"},
+        Maintenance:    &MaintenanceOption{File: marker},
+    }
+    ran := NewRanServer(c, newTestLogger(t))
+    if !ran.Maintenance() {
+        t.Fatal("Maintenance mode should be on if the marker file exists at startup")
+    }
+
+    wait := func(on bool) {
+        t.Helper()
+        for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+            if ran.Maintenance() == on {
+                return
+            }
+        }
+        t.Fatalf("Maintenance mode should be %t", on)
+    }
+
+    if err := os.Remove(marker); err != nil {
+        t.Fatal(err)
+    }
+    wait(false)
+
+    if err := os.WriteFile(marker, nil, 0644); err != nil {
+        t.Fatal(err)
+    }
+    wait(true)
+}
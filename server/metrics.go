@@ -0,0 +1,330 @@
+package server
+
+import gocontext "context"
+import "bytes"
+import "fmt"
+import "net"
+import "net/http"
+import "sort"
+import "strings"
+import "sync"
+import "sync/atomic"
+import "time"
+
+
+// MetricsOption contains options of the metrics endpoint.
+type MetricsOption struct {
+    Path        string  // URL path of the metrics endpoint, e.g. /metrics.
+    Separate    bool    // If true, metrics are served by a separate listener instead of the site.
+}
+
+
+// upper bounds of the response time histogram in seconds, the same as the default buckets of Prometheus clients
+var durationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+
+// methods counted by name, other methods are counted as OTHER, so that clients could not create unlimited series
+var metricsMethods = map[string]bool {
+    http.MethodGet:     true,
+    http.MethodHead:    true,
+    http.MethodPost:    true,
+    http.MethodPut:     true,
+    http.MethodPatch:   true,
+    http.MethodDelete:  true,
+    http.MethodOptions: true,
+}
+
+
+type requestKey struct {
+    code    string  // status class, e.g. 2xx
+    method  string
+}
+
+
+// Metrics collects statistics of requests and connections, and writes them in the Prometheus text format.
+// Methods of a nil Metrics do nothing, so callers need not check if metrics are turned on.
+type Metrics struct {
+    startTime       time.Time
+    inFlight        int64
+
+    mu              sync.Mutex
+    requests        map[requestKey]uint64
+    bytesSent       uint64
+    buckets         []uint64        // number of requests in each bucket of durationBuckets, not cumulative
+    durationSum     float64
+    durationCount   uint64
+    gzipInput       uint64          // bytes written by handlers before gzip compression
+    gzipOutput      uint64          // bytes sent to clients after gzip compression
+    authFailures    map[string]uint64
+    listeners       map[string]*metricsListener
+    connLimiter     *ConnLimiter
+}
+
+
+func NewMetrics() *Metrics {
+    return &Metrics {
+        startTime:      time.Now(),
+        requests:       make(map[requestKey]uint64),
+        buckets:        make([]uint64, len(durationBuckets)),
+        authFailures:   make(map[string]uint64),
+        listeners:      make(map[string]*metricsListener),
+    }
+}
+
+
+// WatchConnLimiter exports the number of connections rejected by the limiter.
+func (this *Metrics) WatchConnLimiter(limiter *ConnLimiter) {
+    if this == nil {
+        return
+    }
+    this.mu.Lock()
+    this.connLimiter = limiter
+    this.mu.Unlock()
+}
+
+
+// begin is called when a request comes in, the returned function is called when the request is done.
+func (this *Metrics) begin() (end func()) {
+    if this == nil {
+        return func() {}
+    }
+    atomic.AddInt64(&this.inFlight, 1)
+    return func() {
+        atomic.AddInt64(&this.inFlight, -1)
+    }
+}
+
+
+// observe records a finished request. uncompressed is number of bytes written before gzip compression.
+func (this *Metrics) observe(sniffer *responseSniffer, r *http.Request, responseTime int64, uncompressed uint64) {
+    if this == nil {
+        return
+    }
+
+    method := r.Method
+    if !metricsMethods[method] {
+        method = "OTHER"
+    }
+    key := requestKey{code: fmt.Sprintf("%dxx", sniffer.Code / 100), method: method}
+
+    seconds := float64(responseTime) / float64(time.Second)
+
+    this.mu.Lock()
+    defer this.mu.Unlock()
+
+    this.requests[key]++
+    this.bytesSent += uint64(sniffer.Size)
+
+    for i, bound := range durationBuckets {
+        if seconds <= bound {
+            this.buckets[i]++
+            break
+        }
+    }
+    this.durationSum += seconds
+    this.durationCount++
+
+    // responses compressed by a proxy backend are not counted, their uncompressed size is unknown
+    if uncompressed > 0 && strings.Contains(strings.ToLower(sniffer.Header().Get("Content-Encoding")), "gzip") {
+        this.gzipInput += uncompressed
+        this.gzipOutput += uint64(sniffer.Size)
+    }
+}
+
+
+// authFailed records a failed authentication, kind is basic, digest or client_certificate.
+func (this *Metrics) authFailed(kind string) {
+    if this == nil {
+        return
+    }
+    this.mu.Lock()
+    this.authFailures[kind]++
+    this.mu.Unlock()
+}
+
+
+// ServeHTTP writes the metrics in the Prometheus text format.
+func (this *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+    var buf bytes.Buffer
+
+    header := func(name, kind, help string) {
+        fmt.Fprintf(&buf, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+    }
+
+    header("ran_start_time_seconds", "gauge", "Start time of ran since unix epoch in seconds.")
+    fmt.Fprintf(&buf, "ran_start_time_seconds %d\n", this.startTime.Unix())
+
+    header("ran_http_requests_in_flight", "gauge", "Number of requests being served.")
+    fmt.Fprintf(&buf, "ran_http_requests_in_flight %d\n", atomic.LoadInt64(&this.inFlight))
+
+    this.mu.Lock()
+
+    header("ran_http_requests_total", "counter", "Number of requests by status class and method.")
+    var keys []requestKey
+    for key := range this.requests {
+        keys = append(keys, key)
+    }
+    sort.Slice(keys, func(i, j int) bool {
+        if keys[i].code != keys[j].code {
+            return keys[i].code < keys[j].code
+        }
+        return keys[i].method < keys[j].method
+    })
+    for _, key := range keys {
+        fmt.Fprintf(&buf, "ran_http_requests_total{code=\"%s\",method=\"%s\"} %d\n", key.code, key.method, this.requests[key])
+    }
+
+    header("ran_http_response_bytes_total", "counter", "Number of bytes sent in response bodies.")
+    fmt.Fprintf(&buf, "ran_http_response_bytes_total %d\n", this.bytesSent)
+
+    header("ran_http_request_duration_seconds", "histogram", "Response time of requests in seconds.")
+    var cumulative uint64
+    for i, bound := range durationBuckets {
+        cumulative += this.buckets[i]
+        fmt.Fprintf(&buf, "ran_http_request_duration_seconds_bucket{le=\"%g\"} %d\n", bound, cumulative)
+    }
+    fmt.Fprintf(&buf, "ran_http_request_duration_seconds_bucket{le=\"+Inf\"} %d\n", this.durationCount)
+    fmt.Fprintf(&buf, "ran_http_request_duration_seconds_sum %g\n", this.durationSum)
+    fmt.Fprintf(&buf, "ran_http_request_duration_seconds_count %d\n", this.durationCount)
+
+    header("ran_http_gzip_input_bytes_total", "counter", "Number of bytes of gzip compressed responses before compression.")
+    fmt.Fprintf(&buf, "ran_http_gzip_input_bytes_total %d\n", this.gzipInput)
+    header("ran_http_gzip_output_bytes_total", "counter", "Number of bytes of gzip compressed responses after compression.")
+    fmt.Fprintf(&buf, "ran_http_gzip_output_bytes_total %d\n", this.gzipOutput)
+
+    header("ran_http_auth_failures_total", "counter", "Number of failed authentications by type.")
+    var kinds []string
+    for kind := range this.authFailures {
+        kinds = append(kinds, kind)
+    }
+    sort.Strings(kinds)
+    for _, kind := range kinds {
+        fmt.Fprintf(&buf, "ran_http_auth_failures_total{type=\"%s\"} %d\n", kind, this.authFailures[kind])
+    }
+
+    header("ran_connections_open", "gauge", "Number of open connections by listener.")
+    var names []string
+    for name := range this.listeners {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    for _, name := range names {
+        fmt.Fprintf(&buf, "ran_connections_open{listener=%q} %d\n", name, atomic.LoadInt64(&this.listeners[name].open))
+    }
+
+    if this.connLimiter != nil {
+        header("ran_connections_rejected_total", "counter", "Number of connections rejected by the connection limits.")
+        fmt.Fprintf(&buf, "ran_connections_rejected_total %d\n", this.connLimiter.Rejected())
+    }
+
+    this.mu.Unlock()
+
+    w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+    w.Write(buf.Bytes())
+}
+
+
+// Listener wraps a net.Listener to count it's open connections.
+func (this *Metrics) Listener(l net.Listener) net.Listener {
+    if this == nil {
+        return l
+    }
+
+    ml := &metricsListener{Listener: l, metrics: this, name: l.Addr().String()}
+    this.mu.Lock()
+    this.listeners[ml.name] = ml
+    this.mu.Unlock()
+    return ml
+}
+
+
+type metricsListener struct {
+    net.Listener
+    metrics     *Metrics
+    name        string
+    open        int64
+}
+
+
+func (this *metricsListener) Accept() (net.Conn, error) {
+    c, err := this.Listener.Accept()
+    if err != nil {
+        return nil, err
+    }
+    atomic.AddInt64(&this.open, 1)
+    return &metricsConn{Conn: c, listener: this}, nil
+}
+
+
+// Close removes the listener from the metrics, e.g. the address is removed from the network interface.
+func (this *metricsListener) Close() error {
+    this.metrics.mu.Lock()
+    if this.metrics.listeners[this.name] == this {
+        delete(this.metrics.listeners, this.name)
+    }
+    this.metrics.mu.Unlock()
+    return this.Listener.Close()
+}
+
+
+type metricsConn struct {
+    net.Conn
+    listener    *metricsListener
+    closeOnce   sync.Once
+}
+
+
+func (this *metricsConn) Close() error {
+    err := this.Conn.Close()
+    this.closeOnce.Do(func() {
+        atomic.AddInt64(&this.listener.open, -1)
+    })
+    return err
+}
+
+
+// key of the uncompressed byte counter in the request context
+type uncompressedKey struct{}
+
+
+// withByteCounter adds a counter of uncompressed bytes to the request context.
+func withByteCounter(r *http.Request, counter *uint64) *http.Request {
+    return r.WithContext(gocontext.WithValue(r.Context(), uncompressedKey{}, counter))
+}
+
+
+type countingWriter struct {
+    http.ResponseWriter
+    counter *uint64
+}
+
+
+func (this *countingWriter) Write(b []byte) (int, error) {
+    n, err := this.ResponseWriter.Write(b)
+    *this.counter += uint64(n)
+    return n, err
+}
+
+
+// countUncompressed counts bytes written by fn before gzip compression, the counter is added by logHandler.
+func countUncompressed(fn http.HandlerFunc) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        if counter, ok := r.Context().Value(uncompressedKey{}).(*uint64); ok {
+            w = &countingWriter{ResponseWriter: w, counter: counter}
+        }
+        fn(w, r)
+    }
+}
+
+
+// metricsHandler serves the metrics on the path of the site, other requests are sent to fn.
+func (this *RanServer) metricsHandler(fn http.HandlerFunc) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        if r.URL.Path == this.config.Metrics.Path {
+            setNoCacheHeader(w)
+            this.metrics.ServeHTTP(w, r)
+            return
+        }
+        fn(w, r)
+    }
+}
@@ -0,0 +1,79 @@
+package server
+
+import "net/http/httptest"
+import "os"
+import "path/filepath"
+import "strings"
+import "testing"
+
+
+func TestMetricsServeHTTP(t *testing.T) {
+    root := t.TempDir()
+    if err := os.WriteFile(filepath.Join(root, "a.txt"), []byte("hello"), 0644); err != nil {
+        t.Fatal(err)
+    }
+
+    c := Config {
+        Root:       root,
+        IndexName:  Index{"index.html"},
+        Metrics:    &MetricsOption{Path: "/metrics"},
+    }
+    handler := NewRanServer(c, newTestLogger(t)).Serve()
+
+    for _, target := range []string{"/a.txt", "/a.txt", "/missing.txt"} {
+        handler(httptest.NewRecorder(), httptest.NewRequest("GET", target, nil))
+    }
+    handler(httptest.NewRecorder(), httptest.NewRequest("PROPFIND", "/a.txt", nil))
+
+    w := httptest.NewRecorder()
+    handler(w, httptest.NewRequest("GET", "/metrics", nil))
+    if w.Code != 200 {
+        t.Fatalf("Status of /metrics is %d, want 200", w.Code)
+    }
+    if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+        t.Errorf("Content-Type is %q", ct)
+    }
+
+    body := w.Body.String()
+    for _, line := range []string {
+        "# TYPE ran_http_requests_total counter",
+        `ran_http_requests_total{code="2xx",method="GET"} 2`,
+        `ran_http_requests_total{code="4xx",method="GET"} 1`,
+        `ran_http_requests_total{code="2xx",method="OTHER"} 1`,
+        "ran_http_response_bytes_total ",
+        `ran_http_request_duration_seconds_bucket{le="+Inf"} 4`,
+        "ran_http_request_duration_seconds_count 4",
+        "# TYPE ran_http_requests_in_flight gauge",
+        "ran_http_requests_in_flight 1",
+    } {
+        if !strings.Contains(body, line) {
+            t.Errorf("Metrics do not contain %q:\n%s", line, body)
+        }
+    }
+}
+
+
+func TestMetricsAuthFailures(t *testing.T) {
+    m := NewMetrics()
+    m.authFailed("basic")
+    m.authFailed("basic")
+    m.authFailed("client_certificate")
+
+    w := httptest.NewRecorder()
+    m.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+
+    body := w.Body.String()
+    for _, line := range []string {
+        `ran_http_auth_failures_total{type="basic"} 2`,
+        `ran_http_auth_failures_total{type="client_certificate"} 1`,
+    } {
+        if !strings.Contains(body, line + "\n") {
+            t.Errorf("Metrics do not contain %q:\n%s", line, body)
+        }
+    }
+
+    // methods of a nil Metrics do nothing
+    var nilMetrics *Metrics
+    nilMetrics.authFailed("basic")
+    nilMetrics.begin()()
+}
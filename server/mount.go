@@ -0,0 +1,344 @@
+package server
+
+import "errors"
+import "fmt"
+import "io"
+import "io/fs"
+import "net/http"
+import "os"
+import "path"
+import "sort"
+import "strings"
+
+
+// Mount serves the files of a separate root under a URL prefix, with it's own settings.
+// Other settings (e.g. index names, gzip and cache policy) are the same as the main site,
+// proxy routes and metrics are not served under the prefix.
+type Mount struct {
+    Prefix      string      // URL path prefix, e.g. /docs
+    Root        string      // Root path of the mount.
+    Files       fs.FS       // Files of the mount. nil means os.DirFS(Root).
+    ListDir     bool        // If no index file provide, show file list of the directory.
+    ServeAll    bool        // If is false, path start with dot will not be served.
+    Auth        *Auth       // If not nil, it takes the place of the authentication of the main site.
+    NoAuth      bool        // If true and Auth is nil, authentication of the main site is turned off for the mount.
+}
+
+
+func (this *Mount) String() string {
+    s := fmt.Sprintf("%s=%s (listdir: %t, serve all: %t", this.Prefix, this.Root, this.ListDir, this.ServeAll)
+    if this.Auth != nil {
+        s += ", auth: " + string(this.Auth.Method)
+    } else if this.NoAuth {
+        s += ", auth: none"
+    }
+    return s + ")"
+}
+
+
+// name of the mount point in the fs.FS of the site, e.g. /docs/ to docs
+func (this *Mount) name() string {
+    return fsName(path.Clean(this.Prefix))
+}
+
+
+func (this *Mount) files() fs.FS {
+    if this.Files == nil {
+        return os.DirFS(this.Root)
+    }
+    return this.Files
+}
+
+
+// Mounts is a list of Mount, it could be used as a repeatable command-line flag.
+type Mounts []*Mount
+
+
+func (this *Mounts) String() string {
+    var s []string
+    for _, m := range *this {
+        s = append(s, m.String())
+    }
+    return strings.Join(s, "; ")
+}
+
+
+// Set parses a value like "<prefix>=<root>[;<option>...]". Options are:
+//
+//  listdir[=<bool>]        show file list of a directory
+//  serve-all[=<bool>]      serve paths start with dot
+//  auth=<user:pass>        username and password of authentication, "none" turns off the authentication of the main site
+//  auth-method=<method>    authentication method, basic or digest
+//
+// e.g. "/downloads=/srv/artifacts;listdir"
+func (this *Mounts) Set(value string) error {
+    pair := strings.SplitN(value, "=", 2)
+    if len(pair) != 2 {
+        return fmt.Errorf("Mount should be in the form of <prefix>=<root>[;<option>...], got '%s'", value)
+    }
+
+    m := new(Mount)
+    m.Prefix = strings.TrimSpace(pair[0])
+    if !strings.HasPrefix(m.Prefix, "/") || m.name() == "." {
+        return fmt.Errorf(`Mount prefix must start with "/" and cannot be "/", got '%s'`, m.Prefix)
+    }
+    m.Prefix = "/" + m.name()
+
+    options := strings.Split(pair[1], ";")
+    m.Root = strings.TrimSpace(options[0])
+    if m.Root == "" {
+        return fmt.Errorf("Root of mount '%s' should be provided", m.Prefix)
+    }
+
+    var method AuthMethod = BasicMethod
+    for _, option := range options[1:] {
+        kv := strings.SplitN(option, "=", 2)
+        key := strings.TrimSpace(kv[0])
+        v := ""
+        if len(kv) == 2 {
+            v = strings.TrimSpace(kv[1])
+        }
+
+        var err error
+        switch key {
+            case "":
+                continue
+            case "listdir":
+                m.ListDir, err = parseOptionBool(v)
+            case "serve-all":
+                m.ServeAll, err = parseOptionBool(v)
+            case "auth":
+                if strings.ToLower(v) == "none" {
+                    m.Auth, m.NoAuth = nil, true
+                    break
+                }
+                m.Auth, m.NoAuth = nil, false
+                m.Auth, err = parseAuthOption(v)
+            case "auth-method":
+                method, err = parseAuthMethodOption(v)
+            default:
+                err = fmt.Errorf("Unknown option '%s'", key)
+        }
+        if err != nil {
+            return fmt.Errorf("Mount '%s': %s", m.Prefix, err)
+        }
+    }
+
+    if m.Auth != nil {
+        m.Auth.Method = method
+    }
+
+    *this = append(*this, m)
+    return nil
+}
+
+
+// match finds the mount of a clean request path, the longest prefix takes effect. nil means no mount matches.
+func (this Mounts) match(p string) *Mount {
+    var matched *Mount
+    for _, m := range this {
+        if (p == m.Prefix || strings.HasPrefix(p, m.Prefix + "/")) &&
+            (matched == nil || len(m.Prefix) > len(matched.Prefix)) {
+            matched = m
+        }
+    }
+    return matched
+}
+
+
+// mountFS is an fs.FS which reads the files under the mount points from the mounts, other files from main.
+// Mount points are shown in the directories of their parents, parents which are not in main are synthesised.
+type mountFS struct {
+    main    fs.FS
+    mounts  Mounts
+}
+
+
+// find returns the fs.FS and the name in it of a name of the site.
+func (this *mountFS) find(name string) (fs.FS, string) {
+    if m := this.mounts.match("/" + name); m != nil {
+        rel := strings.TrimPrefix(strings.TrimPrefix(name, m.name()), "/")
+        if rel == "" {
+            rel = "."
+        }
+        return m.files(), rel
+    }
+    return this.main, name
+}
+
+
+// children returns the names of the mount points and their parents directly under a directory.
+func (this *mountFS) children(dir string) map[string]bool {
+    children := make(map[string]bool)
+    for _, m := range this.mounts {
+        rest := m.name()
+        if dir != "." {
+            if !strings.HasPrefix(rest, dir + "/") {
+                continue
+            }
+            rest = rest[len(dir) + 1:]
+        }
+        children[strings.SplitN(rest, "/", 2)[0]] = true
+    }
+    return children
+}
+
+
+func (this *mountFS) Open(name string) (fs.File, error) {
+    if !fs.ValidPath(name) {
+        return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+    }
+
+    fsys, rel := this.find(name)
+    f, err := fsys.Open(rel)
+
+    children := this.children(name)
+    if len(children) == 0 {
+        return f, err
+    }
+
+    // the directory contains mount points, a file of the same name is hidden
+    if err != nil {
+        if !isNotExist(err) {
+            return nil, err
+        }
+        f = nil
+    } else if info, e := f.Stat(); e != nil || !info.IsDir() {
+        f.Close()
+        f = nil
+    }
+    return &mountDir{name: name, file: f, fsys: this, children: children}, nil
+}
+
+
+// mountDir is a directory which contains mount points, file is nil if the directory is not in main.
+type mountDir struct {
+    name        string
+    file        fs.File
+    fsys        *mountFS
+    children    map[string]bool
+    entries     []fs.DirEntry   // entries of the directory, read by the first call of ReadDir
+    offset      int
+}
+
+
+func (this *mountDir) Stat() (fs.FileInfo, error) {
+    if this.file != nil {
+        return this.file.Stat()
+    }
+    return dirInfo{name: path.Base(this.name)}, nil
+}
+
+
+func (this *mountDir) Read([]byte) (int, error) {
+    return 0, &fs.PathError{Op: "read", Path: this.name, Err: errors.New("is a directory")}
+}
+
+
+func (this *mountDir) Close() error {
+    if this.file != nil {
+        return this.file.Close()
+    }
+    return nil
+}
+
+
+// readAll merges the entries of the directory in main with the mount points.
+func (this *mountDir) readAll() ([]fs.DirEntry, error) {
+    entries := make(map[string]fs.DirEntry)
+
+    if dir, ok := this.file.(fs.ReadDirFile); ok {
+        list, err := dir.ReadDir(-1)
+        if err != nil {
+            return nil, err
+        }
+        for _, entry := range list {
+            entries[entry.Name()] = entry
+        }
+    }
+
+    for name := range this.children {
+        info, err := fs.Stat(this.fsys, path.Join(this.name, name))
+        if err != nil {
+            // the root of the mount is not readable, it's still shown in the listing
+            info = dirInfo{name: name}
+        }
+        entries[name] = fs.FileInfoToDirEntry(renamedInfo{FileInfo: info, name: name})
+    }
+
+    list := make([]fs.DirEntry, 0, len(entries))
+    for _, entry := range entries {
+        list = append(list, entry)
+    }
+    sort.Slice(list, func(i, j int) bool {
+        return list[i].Name() < list[j].Name()
+    })
+    return list, nil
+}
+
+
+func (this *mountDir) ReadDir(n int) ([]fs.DirEntry, error) {
+    if this.entries == nil {
+        var err error
+        if this.entries, err = this.readAll(); err != nil {
+            return nil, err
+        }
+    }
+
+    entries := this.entries[this.offset:]
+    if n > 0 {
+        if len(entries) == 0 {
+            return nil, io.EOF
+        }
+        if n < len(entries) {
+            entries = entries[:n]
+        }
+    }
+    this.offset += len(entries)
+    return entries, nil
+}
+
+
+// renamedInfo is the fs.FileInfo of the root of a mount, with the name of the mount point.
+type renamedInfo struct {
+    fs.FileInfo
+    name string
+}
+
+func (this renamedInfo) Name() string { return this.name }
+
+
+// mountServer creates a RanServer which serves the requests under a mount, other settings are copied from this.
+// Files of the mount are read from the fs.FS of this, which contains the mounts.
+// The mount is protected by the authentication of this, unless it has it's own auth option.
+func (this *RanServer) mountServer(m *Mount) *RanServer {
+    c := this.config
+    c.ListDir = m.ListDir
+    c.ServeAll = m.ServeAll
+    if m.Auth != nil {
+        c.Auth = m.Auth
+    } else if m.NoAuth {
+        c.Auth = nil
+    }
+    return this.siteServer(c, this.fsys)
+}
+
+
+// mountHandler sends the requests under the mount points to their own handlers, other requests are sent to fn.
+func (this *RanServer) mountHandler(fn http.HandlerFunc) http.HandlerFunc {
+    sites := make(map[*Mount]http.HandlerFunc)
+    for _, m := range this.config.Mounts {
+        sites[m] = this.mountServer(m).makeSiteHandler()
+    }
+
+    return func(w http.ResponseWriter, r *http.Request) {
+        // the path is cleaned the same as newContext(), so that "/docs/../private" is not served by the mount of /docs
+        p := path.Clean("/" + strings.ReplaceAll(r.URL.Path, `\`, `/`))
+        m := this.config.Mounts.match(p)
+        if m == nil {
+            fn(w, r)
+            return
+        }
+        sites[m](w, r)
+    }
+}
@@ -0,0 +1,119 @@
+package server
+
+import "net/http"
+import "net/http/httptest"
+import "sort"
+import "strings"
+import "testing"
+import "testing/fstest"
+
+
+func TestMountsMatch(t *testing.T) {
+    var mounts Mounts
+    for _, value := range []string{"/docs=/srv/docs", "/docs/api/=/srv/api", "/downloads=/srv/files"} {
+        if err := mounts.Set(value); err != nil {
+            t.Fatalf("Set(%q): %s", value, err)
+        }
+    }
+
+    tests := []struct {
+        path    string
+        prefix  string
+    }{
+        {"/docs", "/docs"},
+        {"/docs/index.html", "/docs"},
+        {"/docs/api", "/docs/api"},
+        {"/docs/api/v1/users.html", "/docs/api"},
+        {"/docs/apiary", "/docs"},
+        {"/docsets", ""},
+        {"/downloads/a.zip", "/downloads"},
+        {"/", ""},
+        {"/index.html", ""},
+    }
+
+    for _, test := range tests {
+        prefix := ""
+        if m := mounts.match(test.path); m != nil {
+            prefix = m.Prefix
+        }
+        if prefix != test.prefix {
+            t.Errorf("match(%q) = %q, want %q", test.path, prefix, test.prefix)
+        }
+    }
+}
+
+
+func TestMountFSChildren(t *testing.T) {
+    var mounts Mounts
+    for _, value := range []string{"/docs=/srv/docs", "/docs/api=/srv/api", "/a/b/c=/srv/c", "/a/d=/srv/d"} {
+        if err := mounts.Set(value); err != nil {
+            t.Fatalf("Set(%q): %s", value, err)
+        }
+    }
+    fsys := &mountFS{main: fstest.MapFS{}, mounts: mounts}
+
+    tests := []struct {
+        dir         string
+        children    string
+    }{
+        {".", "a,docs"},
+        {"docs", "api"},
+        {"docs/api", ""},
+        {"a", "b,d"},
+        {"a/b", "c"},
+        {"a/b/c", ""},
+        {"ab", ""},
+        {"doc", ""},
+    }
+
+    for _, test := range tests {
+        var names []string
+        for name := range fsys.children(test.dir) {
+            names = append(names, name)
+        }
+        sort.Strings(names)
+        if got := strings.Join(names, ","); got != test.children {
+            t.Errorf("children(%q) = %q, want %q", test.dir, got, test.children)
+        }
+    }
+}
+
+
+func TestMountAuth(t *testing.T) {
+    var mounts Mounts
+    for _, value := range []string{"/private=/srv/private", "/public=/srv/public;auth=none", "/other=/srv/other;auth=a:b"} {
+        if err := mounts.Set(value); err != nil {
+            t.Fatalf("Set(%q): %s", value, err)
+        }
+    }
+    for _, m := range mounts {
+        m.Files = fstest.MapFS{"file.txt": {Data: []byte(m.Prefix)}}
+    }
+
+    ran := New(fstest.MapFS{}, WithAuth(BasicMethod, "admin", "secret"), WithMounts(mounts...))
+
+    tests := []struct {
+        path        string
+        user        string
+        password    string
+        status      int
+    }{
+        {"/public/file.txt", "", "", http.StatusOK},
+        {"/private/file.txt", "admin", "secret", http.StatusOK},
+        {"/other/file.txt", "a", "b", http.StatusOK},
+        {"/other/file.txt", "admin", "secret", http.StatusUnauthorized},
+        {"/private/file.txt", "", "", http.StatusUnauthorized},
+    }
+
+    for _, test := range tests {
+        r := httptest.NewRequest("GET", test.path, nil)
+        if test.user != "" {
+            r.SetBasicAuth(test.user, test.password)
+        }
+        w := httptest.NewRecorder()
+        ran.ServeHTTP(w, r)
+        if w.Code != test.status {
+            t.Errorf("%s (user: %q): status is %d, want %d", test.path, test.user, w.Code, test.status)
+        }
+    }
+}
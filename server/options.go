@@ -0,0 +1,193 @@
+package server
+
+import "io/fs"
+import "net/http"
+import "path"
+
+
+// Logger is the logger used by RanServer, *log.Logger of github.com/m3ng9i/go-utils/log implements it.
+type Logger interface {
+    Debugf(format string, v ...interface{})
+    Info(v ...interface{})
+    Infof(format string, v ...interface{})
+    Errorf(format string, v ...interface{})
+}
+
+
+// nopLogger discards all messages, it is used if no logger is provided to New.
+type nopLogger struct{}
+
+func (nopLogger) Debugf(format string, v ...interface{}) {}
+func (nopLogger) Info(v ...interface{}) {}
+func (nopLogger) Infof(format string, v ...interface{}) {}
+func (nopLogger) Errorf(format string, v ...interface{}) {}
+
+
+// Option changes a setting of a RanServer created by New.
+type Option func(*RanServer)
+
+
+// WithConfig uses all the settings of a Config. Options after it override the settings.
+// Root of the Config is only used by live reload, files are read from the fs.FS given to New.
+func WithConfig(c Config) Option {
+    return func(this *RanServer) {
+        this.config = c
+    }
+}
+
+
+// WithLogger sets the logger, access logs and errors are written to it. Default is to discard all messages.
+func WithLogger(logger Logger) Option {
+    return func(this *RanServer) {
+        this.logger = logger
+    }
+}
+
+
+// WithIndexNames sets file names of index, priority depends on the order of names.
+// Default is index.html and index.htm.
+func WithIndexNames(names ...string) Option {
+    return func(this *RanServer) {
+        this.config.IndexName = Index(names)
+    }
+}
+
+
+// WithListDir sets if show file list of a directory which has no index file. Default is false.
+func WithListDir(on bool) Option {
+    return func(this *RanServer) {
+        this.config.ListDir = on
+    }
+}
+
+
+// WithServeAll sets if serve paths start with dot. Default is false.
+func WithServeAll(on bool) Option {
+    return func(this *RanServer) {
+        this.config.ServeAll = on
+    }
+}
+
+
+// WithGzip turns on or off gzip compression. Default is true.
+func WithGzip(on bool) Option {
+    return func(this *RanServer) {
+        this.config.Gzip = on
+    }
+}
+
+
+// WithNoCache sets if send no-cache headers. Default is false.
+func WithNoCache(on bool) Option {
+    return func(this *RanServer) {
+        this.config.NoCache = on
+    }
+}
+
+
+// WithCachePolicy sets Cache-Control rules matched by file extension or path pattern.
+func WithCachePolicy(policy CachePolicy) Option {
+    return func(this *RanServer) {
+        this.config.CachePolicy = policy
+    }
+}
+
+
+// WithCORS sets if send CORS headers. Default is false.
+func WithCORS(on bool) Option {
+    return func(this *RanServer) {
+        this.config.CORS = on
+    }
+}
+
+
+// WithAuth turns on authentication of all paths.
+func WithAuth(method AuthMethod, username, password string) Option {
+    return func(this *RanServer) {
+        this.config.Auth = &Auth{Method: method, Username: username, Password: password}
+    }
+}
+
+
+// With404 sets the custom 404 file, p is a path of the fs.FS, e.g. /404.html.
+func With404(p string) Option {
+    return func(this *RanServer) {
+        this.config.Path404 = &ErrorFilePath{Rel: path.Clean("/" + p)}
+    }
+}
+
+
+// With401 sets the custom 401 file, p is a path of the fs.FS, e.g. /401.html.
+func With401(p string) Option {
+    return func(this *RanServer) {
+        this.config.Path401 = &ErrorFilePath{Rel: path.Clean("/" + p)}
+    }
+}
+
+
+// WithHeader sets a header to the responses.
+func WithHeader(name, value string) Option {
+    return func(this *RanServer) {
+        if this.config.Header == nil {
+            this.config.Header = Header{}
+        }
+        http.Header(this.config.Header).Add(name, value)
+    }
+}
+
+
+// WithVirtualHosts serves the requests of the host names of the virtual hosts by them,
+// files of a virtual host are read from it's Files, e.g. an fs.Sub of an embed.FS.
+func WithVirtualHosts(hosts ...*VirtualHost) Option {
+    return func(this *RanServer) {
+        this.config.VirtualHosts = append(this.config.VirtualHosts, hosts...)
+    }
+}
+
+
+// WithMounts serves the files of the mounts under their prefixes, files of a mount are read from it's Files.
+func WithMounts(mounts ...*Mount) Option {
+    return func(this *RanServer) {
+        this.config.Mounts = append(this.config.Mounts, mounts...)
+    }
+}
+
+
+// New creates a RanServer which serves files of fsys, e.g. os.DirFS("/var/www") or an embed.FS.
+// The RanServer is an http.Handler:
+//
+//  //go:embed site
+//  var site embed.FS
+//
+//  sub, _ := fs.Sub(site, "site")
+//  http.Handle("/", server.New(sub, server.WithListDir(true)))
+func New(fsys fs.FS, options ...Option) *RanServer {
+    this := &RanServer {
+        fsys:           fsys,
+        logger:         nopLogger{},
+        maintenance:    new(maintenanceState),
+        config:         Config {
+            IndexName:  Index{"index.html", "index.htm"},
+            Gzip:       true,
+        },
+    }
+
+    for _, option := range options {
+        option(this)
+    }
+
+    if len(this.config.Mounts) > 0 {
+        this.fsys = &mountFS{main: this.fsys, mounts: this.config.Mounts}
+    }
+    if this.config.Metrics != nil {
+        this.metrics = NewMetrics()
+    }
+    if this.config.Maintenance != nil {
+        this.SetMaintenance(this.config.Maintenance.On)
+        if this.config.Maintenance.File != "" {
+            this.watchMaintenanceFile(this.config.Maintenance.File)
+        }
+    }
+
+    return this
+}
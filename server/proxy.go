@@ -0,0 +1,262 @@
+package server
+
+import gocontext "context"
+import "errors"
+import "fmt"
+import "net"
+import "net/http"
+import "net/http/httputil"
+import "net/url"
+import "strings"
+import "time"
+
+
+// ProxyRoute forwards requests under Prefix to Target.
+type ProxyRoute struct {
+    Prefix      string      // URL path prefix, e.g. /api
+    Target      *url.URL    // URL of the backend, e.g. http://127.0.0.1:9000
+    PassAuth    bool        // If true, the Authorization header of the client is sent to the backend
+                            // even if authentication of ran is turned on.
+}
+
+
+// match checks if a request path is under the prefix of the route.
+func (this *ProxyRoute) match(p string) bool {
+    prefix := strings.TrimSuffix(this.Prefix, "/")
+    return p == prefix || strings.HasPrefix(p, prefix + "/")
+}
+
+
+// ProxyRoutes is a list of ProxyRoute, it could be used as a repeatable command-line flag.
+type ProxyRoutes []ProxyRoute
+
+
+func (this *ProxyRoutes) String() string {
+    var s []string
+    for _, route := range *this {
+        item := fmt.Sprintf("%s=%s", route.Prefix, route.Target.String())
+        if route.PassAuth {
+            item += " (pass auth)"
+        }
+        s = append(s, item)
+    }
+    return strings.Join(s, ", ")
+}
+
+
+// Set parses a value like "/api=http://127.0.0.1:9000[;pass-auth]".
+// With the pass-auth option, the Authorization header of the client is sent to the backend.
+func (this *ProxyRoutes) Set(value string) error {
+    pair := strings.SplitN(value, "=", 2)
+    if len(pair) != 2 {
+        return fmt.Errorf("Proxy route should be in the form of <prefix>=<url>[;pass-auth], got '%s'", value)
+    }
+
+    passAuth := false
+    options := strings.Split(pair[1], ";")
+    pair[1] = options[0]
+    for _, option := range options[1:] {
+        kv := strings.SplitN(option, "=", 2)
+        key := strings.TrimSpace(kv[0])
+        v := ""
+        if len(kv) == 2 {
+            v = strings.TrimSpace(kv[1])
+        }
+
+        var err error
+        switch key {
+            case "":
+                continue
+            case "pass-auth":
+                passAuth, err = parseOptionBool(v)
+            default:
+                err = fmt.Errorf("Unknown option '%s'", key)
+        }
+        if err != nil {
+            return fmt.Errorf("Proxy route '%s': %s", pair[0], err)
+        }
+    }
+
+    prefix := strings.TrimSpace(pair[0])
+    if !strings.HasPrefix(prefix, "/") {
+        return fmt.Errorf(`Proxy prefix must start with "/", got %s`, prefix)
+    }
+
+    target, err := url.Parse(strings.TrimSpace(pair[1]))
+    if err != nil {
+        return fmt.Errorf("Invalid proxy url '%s': %s", pair[1], err)
+    }
+    if (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+        return fmt.Errorf("Proxy url must start with http:// or https://, got '%s'", pair[1])
+    }
+
+    *this = append(*this, ProxyRoute{Prefix: prefix, Target: target, PassAuth: passAuth})
+    return nil
+}
+
+
+// Proxy contains options of reverse proxy.
+type Proxy struct {
+    Routes          ProxyRoutes
+    StripPrefix     bool            // If true, remove the prefix of route from the path before forwarding.
+    PreserveHost    bool            // If true, send the Host header of the client to the backend.
+    Timeout         time.Duration   // Timeout of connecting to the backend and waiting for the response headers.
+    Headers         Header          // Headers set to the requests sent to the backend.
+}
+
+
+func (this *Proxy) String() string {
+    s := fmt.Sprintf("%s (strip prefix: %t, preserve host: %t, timeout: %s)",
+        this.Routes.String(), this.StripPrefix, this.PreserveHost, this.Timeout)
+    if len(this.Headers) > 0 {
+        s += ", headers: " + this.Headers.String()
+    }
+    return s
+}
+
+
+type routeProxy struct {
+    route   ProxyRoute
+    proxy   *httputil.ReverseProxy
+}
+
+
+// rewrite the Location header of a backend response to the url of ran server.
+func (this *RanServer) rewriteLocation(route ProxyRoute, resp *http.Response) {
+    location := resp.Header.Get("Location")
+    if location == "" {
+        return
+    }
+
+    u, err := url.Parse(location)
+    if err != nil {
+        return
+    }
+    if u.IsAbs() && u.Host != route.Target.Host {
+        return
+    }
+
+    p := u.Path
+    if !strings.HasPrefix(p, "/") {
+        // relative location is resolved by the client
+        return
+    }
+
+    targetPath := strings.TrimSuffix(route.Target.Path, "/")
+    if targetPath != "" {
+        if p != targetPath && !strings.HasPrefix(p, targetPath + "/") {
+            return
+        }
+        p = strings.TrimPrefix(p, targetPath)
+    }
+    if this.config.Proxy.StripPrefix {
+        p = strings.TrimSuffix(route.Prefix, "/") + p
+    }
+    if p == "" {
+        p = "/"
+    }
+
+    newURL := url.URL{Path: p, RawQuery: u.RawQuery, Fragment: u.Fragment}
+    resp.Header.Set("Location", newURL.String())
+}
+
+
+func (this *RanServer) newRouteProxy(route ProxyRoute) *routeProxy {
+    option := this.config.Proxy
+    proxy := httputil.NewSingleHostReverseProxy(route.Target)
+
+    director := proxy.Director
+    proxy.Director = func(r *http.Request) {
+        originalHost := r.Host
+
+        if option.StripPrefix {
+            r.URL.Path = strings.TrimPrefix(r.URL.Path, strings.TrimSuffix(route.Prefix, "/"))
+            if !strings.HasPrefix(r.URL.Path, "/") {
+                r.URL.Path = "/" + r.URL.Path
+            }
+            r.URL.RawPath = ""
+        }
+
+        director(r)
+
+        if !option.PreserveHost {
+            r.Host = route.Target.Host
+        }
+
+        // X-Forwarded-For is set by httputil.ReverseProxy
+        r.Header.Set("X-Forwarded-Host", originalHost)
+        if r.TLS != nil {
+            r.Header.Set("X-Forwarded-Proto", "https")
+        } else {
+            r.Header.Set("X-Forwarded-Proto", "http")
+        }
+
+        // credentials of ran are not sent to the backend, unless the route opts in
+        if this.config.Auth != nil && !route.PassAuth {
+            r.Header.Del("Authorization")
+        }
+
+        for key, value := range option.Headers {
+            r.Header[key] = value
+        }
+    }
+
+    proxy.ModifyResponse = func(resp *http.Response) error {
+        this.rewriteLocation(route, resp)
+        return nil
+    }
+
+    proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+        this.logger.Errorf("#%s: Proxy error: %s", r.Header.Get("X-Request-Id"), err)
+
+        var netErr net.Error
+        if errors.Is(err, gocontext.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+            Error(w, http.StatusGatewayTimeout)
+        } else {
+            Error(w, http.StatusBadGateway)
+        }
+    }
+
+    proxy.Transport = &http.Transport {
+        Proxy: http.ProxyFromEnvironment,
+        DialContext: (&net.Dialer {
+            Timeout:    option.Timeout,
+            KeepAlive:  30 * time.Second,
+        }).DialContext,
+        MaxIdleConns:           100,
+        IdleConnTimeout:        90 * time.Second,
+        TLSHandshakeTimeout:    option.Timeout,
+        ResponseHeaderTimeout:  option.Timeout,
+        ExpectContinueTimeout:  1 * time.Second,
+    }
+
+    // flush the response immediately, so that streaming responses like server-sent events work.
+    proxy.FlushInterval = -1
+
+    return &routeProxy{route: route, proxy: proxy}
+}
+
+
+// proxyHandler forwards requests matching a proxy route to the backend, other requests are sent to fn.
+func (this *RanServer) proxyHandler(fn http.HandlerFunc) http.HandlerFunc {
+    var proxies []*routeProxy
+    for _, route := range this.config.Proxy.Routes {
+        proxies = append(proxies, this.newRouteProxy(route))
+    }
+
+    return func(w http.ResponseWriter, r *http.Request) {
+        for _, p := range proxies {
+            if p.route.match(r.URL.Path) {
+                requestId := w.Header().Get("X-Request-Id")
+                this.logger.Debugf("#%s: Proxy to %s", requestId, p.route.Target.String())
+
+                // send the request id to the backend
+                r.Header.Set("X-Request-Id", requestId)
+
+                p.proxy.ServeHTTP(w, r)
+                return
+            }
+        }
+        fn(w, r)
+    }
+}
@@ -0,0 +1,137 @@
+package server
+
+import "encoding/json"
+import "io"
+import "net/http"
+import "net/http/httptest"
+import "testing"
+import "time"
+import "github.com/m3ng9i/go-utils/log"
+
+
+func newTestLogger(t *testing.T) *log.Logger {
+    var config log.Config
+    config.Layout       = log.LY_DEFAULT
+    config.LayoutStyle  = log.LS_DEFAULT
+    config.TimeFormat   = log.TF_DEFAULT
+    config.Level        = log.FATAL
+
+    logger, err := log.New(io.Discard, config)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return logger
+}
+
+
+// request received by the backend
+type backendRequest struct {
+    Path            string
+    Host            string
+    Authorization   string
+    ForwardedHost   string
+    ForwardedProto  string
+}
+
+
+func TestProxyDirector(t *testing.T) {
+    backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        json.NewEncoder(w).Encode(backendRequest {
+            Path:           r.URL.Path,
+            Host:           r.Host,
+            Authorization:  r.Header.Get("Authorization"),
+            ForwardedHost:  r.Header.Get("X-Forwarded-Host"),
+            ForwardedProto: r.Header.Get("X-Forwarded-Proto"),
+        })
+    }))
+    defer backend.Close()
+
+    var routes ProxyRoutes
+    for _, value := range []string{"/api=" + backend.URL, "/pass=" + backend.URL + ";pass-auth"} {
+        if err := routes.Set(value); err != nil {
+            t.Fatal(err)
+        }
+    }
+
+    tests := []struct {
+        url         string
+        auth        bool    // turn on auth of ran
+        strip       bool
+        want        backendRequest
+    }{
+        {"http://site.test/api/users", true, false,
+            backendRequest{Path: "/api/users", ForwardedHost: "site.test", ForwardedProto: "http"}},
+        {"http://site.test/pass/users", true, false,
+            backendRequest{Path: "/pass/users", Authorization: "Basic YWRtaW46c2VjcmV0", ForwardedHost: "site.test", ForwardedProto: "http"}},
+        {"https://site.test/api/users", false, true,
+            backendRequest{Path: "/users", Authorization: "Basic YWRtaW46c2VjcmV0", ForwardedHost: "site.test", ForwardedProto: "https"}},
+        {"http://site.test/api", false, true,
+            backendRequest{Path: "/", Authorization: "Basic YWRtaW46c2VjcmV0", ForwardedHost: "site.test", ForwardedProto: "http"}},
+    }
+
+    for _, test := range tests {
+        c := Config {
+            Root:   t.TempDir(),
+            Proxy:  &Proxy{Routes: routes, StripPrefix: test.strip, Timeout: time.Second},
+        }
+        if test.auth {
+            c.Auth = &Auth{Method: BasicMethod, Username: "admin", Password: "secret"}
+        }
+        handler := NewRanServer(c, newTestLogger(t)).Serve()
+
+        r := httptest.NewRequest("GET", test.url, nil)
+        r.SetBasicAuth("admin", "secret")
+        w := httptest.NewRecorder()
+        handler(w, r)
+
+        var got backendRequest
+        if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+            t.Errorf("%s: status %d, invalid response: %s", test.url, w.Code, err)
+            continue
+        }
+        test.want.Host = backend.Listener.Addr().String()
+        if got != test.want {
+            t.Errorf("%s (auth: %t, strip prefix: %t): backend gets %+v, want %+v", test.url, test.auth, test.strip, got, test.want)
+        }
+    }
+}
+
+
+func TestProxyErrors(t *testing.T) {
+    slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        time.Sleep(500 * time.Millisecond)
+    }))
+    defer slow.Close()
+
+    closed := httptest.NewServer(http.NotFoundHandler())
+    closed.Close()
+
+    var routes ProxyRoutes
+    for _, value := range []string{"/slow=" + slow.URL, "/closed=" + closed.URL} {
+        if err := routes.Set(value); err != nil {
+            t.Fatal(err)
+        }
+    }
+
+    c := Config {
+        Root:   t.TempDir(),
+        Proxy:  &Proxy{Routes: routes, Timeout: 100 * time.Millisecond},
+    }
+    handler := NewRanServer(c, newTestLogger(t)).Serve()
+
+    tests := []struct {
+        path    string
+        status  int
+    }{
+        {"/slow", http.StatusGatewayTimeout},
+        {"/closed", http.StatusBadGateway},
+    }
+
+    for _, test := range tests {
+        w := httptest.NewRecorder()
+        handler(w, httptest.NewRequest("GET", test.path, nil))
+        if w.Code != test.status {
+            t.Errorf("%s: status is %d, want %d", test.path, w.Code, test.status)
+        }
+    }
+}
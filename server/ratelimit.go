@@ -0,0 +1,362 @@
+package server
+
+import "bufio"
+import "errors"
+import "fmt"
+import "math"
+import "net"
+import "net/http"
+import "strconv"
+import "strings"
+import "sync"
+import "time"
+import hhelper "github.com/m3ng9i/go-utils/http"
+
+
+// ByteSize is a number of bytes, it could be set by a string like 512, 64K, 10M or 1G.
+type ByteSize int64
+
+
+func (this *ByteSize) String() string {
+    n := int64(*this)
+    switch {
+        case n >= 1 << 30 && n % (1 << 30) == 0:
+            return fmt.Sprintf("%dG", n >> 30)
+        case n >= 1 << 20 && n % (1 << 20) == 0:
+            return fmt.Sprintf("%dM", n >> 20)
+        case n >= 1 << 10 && n % (1 << 10) == 0:
+            return fmt.Sprintf("%dK", n >> 10)
+    }
+    return strconv.FormatInt(n, 10)
+}
+
+
+func (this *ByteSize) Set(value string) error {
+    s := strings.ToUpper(strings.TrimSpace(value))
+    s = strings.TrimSuffix(s, "B")
+
+    var unit int64 = 1
+    if s != "" {
+        switch s[len(s) - 1] {
+            case 'K':
+                unit = 1 << 10
+            case 'M':
+                unit = 1 << 20
+            case 'G':
+                unit = 1 << 30
+        }
+        if unit > 1 {
+            s = s[:len(s) - 1]
+        }
+    }
+
+    n, err := strconv.ParseFloat(s, 64)
+    if err != nil || n < 0 {
+        return fmt.Errorf("Invalid size '%s'", value)
+    }
+    *this = ByteSize(n * float64(unit))
+    return nil
+}
+
+
+// PathRateLimit overrides the rate limit of the paths start with Prefix.
+type PathRateLimit struct {
+    Prefix      string      // URL path prefix, e.g. /downloads/
+    Requests    float64     // Requests per second per client IP. 0 means no limit.
+    Bandwidth   ByteSize    // Bytes per second per client IP. 0 means no limit.
+}
+
+
+// PathRateLimits is a list of PathRateLimit, it could be used as a repeatable command-line flag.
+type PathRateLimits []PathRateLimit
+
+
+func (this *PathRateLimits) String() string {
+    var s []string
+    for _, p := range *this {
+        s = append(s, fmt.Sprintf("%s=%g,%s", p.Prefix, p.Requests, p.Bandwidth.String()))
+    }
+    return strings.Join(s, "; ")
+}
+
+
+// Set parses a value like "<prefix>=<requests>[,<bandwidth>]", e.g. "/downloads/=2,1M".
+func (this *PathRateLimits) Set(value string) error {
+    pair := strings.SplitN(value, "=", 2)
+    if len(pair) != 2 || pair[0] == "" {
+        return fmt.Errorf("Path rate limit should be in the form of <prefix>=<requests>[,<bandwidth>], got '%s'", value)
+    }
+
+    var p PathRateLimit
+    p.Prefix = strings.TrimSpace(pair[0])
+
+    limits := strings.SplitN(pair[1], ",", 2)
+
+    var err error
+    p.Requests, err = strconv.ParseFloat(strings.TrimSpace(limits[0]), 64)
+    if err != nil || p.Requests < 0 {
+        return fmt.Errorf("Invalid requests per second in path rate limit '%s'", value)
+    }
+
+    if len(limits) == 2 {
+        if err = p.Bandwidth.Set(limits[1]); err != nil {
+            return err
+        }
+    }
+
+    *this = append(*this, p)
+    return nil
+}
+
+
+// RateLimit contains limits of request rate and bandwidth, it uses the token bucket algorithm.
+type RateLimit struct {
+    Requests        float64         // Requests per second per client IP. 0 means no limit.
+    Burst           int             // Max number of requests a client could send at once. Default is Requests rounded up.
+    Bandwidth       ByteSize        // Bytes per second per client IP. 0 means no limit.
+    GlobalBandwidth ByteSize        // Bytes per second shared by all the clients. 0 means no limit.
+    Paths           PathRateLimits  // Override the limits by path prefix, the longest matched prefix takes effect.
+}
+
+
+func (this *RateLimit) String() string {
+    s := fmt.Sprintf("requests: %g/s, burst: %d, bandwidth: %s/s, global bandwidth: %s/s",
+        this.Requests, this.Burst, this.Bandwidth.String(), this.GlobalBandwidth.String())
+    if len(this.Paths) > 0 {
+        s += ", paths: " + this.Paths.String()
+    }
+    return s
+}
+
+
+// tokenBucket is a token bucket. Tokens are added to the bucket at rate per second, up to burst.
+type tokenBucket struct {
+    mu      sync.Mutex
+    rate    float64
+    burst   float64
+    tokens  float64
+    last    time.Time
+}
+
+
+func newTokenBucket(rate, burst float64) *tokenBucket {
+    return &tokenBucket {
+        rate:   rate,
+        burst:  burst,
+        tokens: burst,
+        last:   time.Now(),
+    }
+}
+
+
+// reserve takes n tokens from the bucket and returns how long the caller should wait before the tokens are available.
+// If reject is true and tokens are not enough, no token will be taken.
+func (this *tokenBucket) reserve(n float64, reject bool) time.Duration {
+    this.mu.Lock()
+    defer this.mu.Unlock()
+
+    now := time.Now()
+    this.tokens = math.Min(this.burst, this.tokens + now.Sub(this.last).Seconds() * this.rate)
+    this.last = now
+
+    if this.tokens >= n {
+        this.tokens -= n
+        return 0
+    }
+
+    wait := time.Duration((n - this.tokens) / this.rate * float64(time.Second))
+    if !reject {
+        this.tokens -= n
+    }
+    return wait
+}
+
+
+// clientLimit contains the token buckets of a client on a group of paths.
+type clientLimit struct {
+    requests    *tokenBucket
+    bandwidth   *tokenBucket
+    lastSeen    time.Time
+}
+
+
+// how long a clientLimit could be kept after the last request
+const clientLimitIdle = 10 * time.Minute
+
+// max number of bytes written to a throttled response at once
+const throttleChunk = 16 * 1024
+
+
+type rateLimiter struct {
+    config      RateLimit
+    global      *tokenBucket
+    mu          sync.Mutex
+    clients     map[string]*clientLimit
+    lastCleanup time.Time
+}
+
+
+func newRateLimiter(config RateLimit) *rateLimiter {
+    if config.Requests > 0 && config.Burst <= 0 {
+        config.Burst = int(math.Ceil(config.Requests))
+    }
+
+    limiter := &rateLimiter {
+        config:         config,
+        clients:        make(map[string]*clientLimit),
+        lastCleanup:    time.Now(),
+    }
+    if config.GlobalBandwidth > 0 {
+        limiter.global = newBandwidthBucket(config.GlobalBandwidth)
+    }
+    return limiter
+}
+
+
+func newBandwidthBucket(bandwidth ByteSize) *tokenBucket {
+    return newTokenBucket(float64(bandwidth), math.Max(float64(bandwidth), throttleChunk))
+}
+
+
+// find limits of a request path, return the matched prefix and the limits.
+func (this *rateLimiter) limits(p string) (prefix string, requests float64, burst int, bandwidth ByteSize) {
+    requests, burst, bandwidth = this.config.Requests, this.config.Burst, this.config.Bandwidth
+
+    for _, item := range this.config.Paths {
+        if strings.HasPrefix(p, item.Prefix) && len(item.Prefix) > len(prefix) {
+            prefix, requests, bandwidth = item.Prefix, item.Requests, item.Bandwidth
+            burst = int(math.Ceil(requests))
+        }
+    }
+    return
+}
+
+
+// get the clientLimit of an ip on a path, return nil if the path has no limits.
+func (this *rateLimiter) client(ip, p string) *clientLimit {
+    prefix, requests, burst, bandwidth := this.limits(p)
+    if requests <= 0 && bandwidth <= 0 {
+        return nil
+    }
+
+    this.mu.Lock()
+    defer this.mu.Unlock()
+
+    now := time.Now()
+
+    // remove idle clients
+    if now.Sub(this.lastCleanup) > clientLimitIdle {
+        for key, c := range this.clients {
+            if now.Sub(c.lastSeen) > clientLimitIdle {
+                delete(this.clients, key)
+            }
+        }
+        this.lastCleanup = now
+    }
+
+    key := ip + " " + prefix
+    c, ok := this.clients[key]
+    if !ok {
+        c = new(clientLimit)
+        if requests > 0 {
+            c.requests = newTokenBucket(requests, float64(burst))
+        }
+        if bandwidth > 0 {
+            c.bandwidth = newBandwidthBucket(bandwidth)
+        }
+        this.clients[key] = c
+    }
+    c.lastSeen = now
+    return c
+}
+
+
+// throttledWriter limits the speed of writing to a ResponseWriter.
+type throttledWriter struct {
+    http.ResponseWriter
+    buckets []*tokenBucket
+}
+
+
+func (this *throttledWriter) Write(b []byte) (n int, err error) {
+    for len(b) > 0 {
+        chunk := b
+        if len(chunk) > throttleChunk {
+            chunk = chunk[:throttleChunk]
+        }
+
+        var wait time.Duration
+        for _, bucket := range this.buckets {
+            if d := bucket.reserve(float64(len(chunk)), false); d > wait {
+                wait = d
+            }
+        }
+        time.Sleep(wait)
+
+        m, e := this.ResponseWriter.Write(chunk)
+        n += m
+        if e != nil {
+            err = e
+            return
+        }
+        b = b[len(chunk):]
+    }
+    return
+}
+
+
+func (this *throttledWriter) Flush() {
+    if f, ok := this.ResponseWriter.(http.Flusher); ok {
+        f.Flush()
+    }
+}
+
+
+// Hijacked connections are not throttled.
+func (this *throttledWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+    h, ok := this.ResponseWriter.(http.Hijacker)
+    if !ok {
+        return nil, nil, errors.New("The ResponseWriter does not support hijacking")
+    }
+    return h.Hijack()
+}
+
+
+// rateLimitHandler rejects requests exceeding the rate limit with 429 Too Many Requests,
+// and limits the bandwidth of the response.
+func (this *RanServer) rateLimitHandler(fn http.HandlerFunc) http.HandlerFunc {
+    limiter := newRateLimiter(*this.config.RateLimit)
+
+    return func(w http.ResponseWriter, r *http.Request) {
+        ip := hhelper.GetIP(r)
+        c := limiter.client(ip, r.URL.Path)
+
+        var buckets []*tokenBucket
+
+        if c != nil {
+            if c.requests != nil {
+                if wait := c.requests.reserve(1, true); wait > 0 {
+                    requestId := w.Header().Get("X-Request-Id")
+                    this.logger.Debugf("#%s: Rate limit exceeded: %s", requestId, ip)
+
+                    w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+                    Error(w, http.StatusTooManyRequests)
+                    return
+                }
+            }
+            if c.bandwidth != nil {
+                buckets = append(buckets, c.bandwidth)
+            }
+        }
+
+        if limiter.global != nil {
+            buckets = append(buckets, limiter.global)
+        }
+
+        if len(buckets) > 0 {
+            w = &throttledWriter{ResponseWriter: w, buckets: buckets}
+        }
+
+        fn(w, r)
+    }
+}
@@ -0,0 +1,39 @@
+package server
+
+import "testing"
+import "time"
+
+
+func TestTokenBucketReserve(t *testing.T) {
+    type step struct {
+        n       float64
+        reject  bool
+        wait    time.Duration
+    }
+
+    tests := []struct {
+        name    string
+        rate    float64
+        burst   float64
+        steps   []step
+    }{
+        {"within burst", 1, 3, []step{{1, false, 0}, {1, false, 0}, {1, false, 0}}},
+        {"over burst", 1, 2, []step{{1, false, 0}, {1, false, 0}, {1, false, time.Second}, {1, false, 2 * time.Second}}},
+        {"reject keeps tokens", 1, 1, []step{{1, true, 0}, {1, true, time.Second}, {1, true, time.Second}}},
+        {"large request", 10, 10, []step{{15, false, 500 * time.Millisecond}, {5, true, time.Second}}},
+        {"partial tokens", 2, 4, []step{{3, false, 0}, {2, false, 500 * time.Millisecond}}},
+    }
+
+    // tokens added during the test are ignored, the rates are low enough
+    const tolerance = 50 * time.Millisecond
+
+    for _, test := range tests {
+        bucket := newTokenBucket(test.rate, test.burst)
+        for i, s := range test.steps {
+            wait := bucket.reserve(s.n, s.reject)
+            if wait < s.wait - tolerance || wait > s.wait {
+                t.Errorf("%s: step %d: reserve(%g, %t) = %s, want %s", test.name, i, s.n, s.reject, wait, s.wait)
+            }
+        }
+    }
+}
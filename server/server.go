@@ -1,19 +1,28 @@
 package server
 
+import "bytes"
 import "fmt"
 import "errors"
+import "net"
 import "net/http"
+import "net/url"
+import "strconv"
+import "strings"
 import "os"
+import "io"
+import "io/fs"
+import "sync"
+import "sync/atomic"
 import "time"
 import "math/rand"
 import "crypto/md5"
-import "github.com/m3ng9i/go-utils/log"
+import "crypto/tls"
 import hhelper "github.com/m3ng9i/go-utils/http"
 
 
-// serveFile() serve any request with content pointed by abspath.
-func serveFile(w http.ResponseWriter, r *http.Request, abspath string, setLastModified bool) error {
-    f, err := os.Open(abspath)
+// serveFile() serve any request with content of a file in fsys.
+func serveFile(w http.ResponseWriter, r *http.Request, fsys fs.FS, name string, setLastModified bool) error {
+    f, err := fsys.Open(name)
     if err != nil {
         return err
     }
@@ -43,23 +52,47 @@ func serveFile(w http.ResponseWriter, r *http.Request, abspath string, setLastMo
         lastModified = info.ModTime()
     }
 
+    // http.ServeContent() needs a seeker, files of os.DirFS and embed.FS are seekable,
+    // files of other fs.FS which are not seekable are read into memory.
+    content, ok := f.(io.ReadSeeker)
+    if !ok {
+        b, err := io.ReadAll(f)
+        if err != nil {
+            return err
+        }
+        content = bytes.NewReader(b)
+    }
+
     // http.ServeContent() always return a status code of 200.
-    http.ServeContent(w, r, filename, lastModified, f)
+    http.ServeContent(w, r, filename, lastModified, content)
     return nil
 }
 
 
 type RanServer struct {
     config      Config
-    logger      *log.Logger
+    fsys        fs.FS       // files of the site
+    logger      Logger
+    metrics     *Metrics    // nil if metrics are not collected
+    certificate func() *tls.Certificate // returns the certificate in use, nil if TLS is off
+    handler     atomic.Value    // http.HandlerFunc, the request handler chain made by makeHandler()
+    maintenance *maintenanceState // shared with the RanServer made by SetConfig
+    site        atomic.Value    // *RanServer made by SetConfig, which has the settings in use
+    liveReload  *liveReload     // nil if live reload is not started
+    serveOnce   sync.Once
+    serve       http.HandlerFunc // handler returned by Serve(), used by ServeHTTP()
 }
 
 
-func NewRanServer(c Config, logger *log.Logger) *RanServer {
-    return &RanServer {
-        config:     c,
-        logger:     logger,
-    }
+// NewRanServer creates a RanServer which serves files under c.Root.
+func NewRanServer(c Config, logger Logger) *RanServer {
+    return New(os.DirFS(c.Root), WithConfig(c), WithLogger(logger))
+}
+
+
+// Metrics returns the metrics collector, if metrics are not collected, nil is returned.
+func (this *RanServer) Metrics() *Metrics {
+    return this.metrics
 }
 
 
@@ -82,11 +115,20 @@ func setCORSHeader(w http.ResponseWriter, r *http.Request) {
 }
 
 
-func (this *RanServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+// headerHandler sets the headers of Config.Header to the responses.
+func (this *RanServer) headerHandler(fn http.HandlerFunc) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        for key, values := range this.config.Header {
+            w.Header()[key] = values
+        }
+        fn(w, r)
+    }
+}
+
 
-    requestId := string(getRequestId(r.URL.String()))
+func (this *RanServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
 
-    w.Header().Set("X-Request-Id", requestId)
+    requestId := w.Header().Get("X-Request-Id")
 
     if (this.config.NoCache) {
         setNoCacheHeader(w)
@@ -98,7 +140,7 @@ func (this *RanServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
 
     this.logger.Debugf("#%s: r.URL: [%s], r.URL.Path: [%s]", requestId, r.URL.String(), r.URL.Path)
 
-    context, err := newContext(this.config, r)
+    context, err := newContext(this.config, this.fsys, r)
     if err != nil {
         Error(w, 500)
         this.logger.Errorf("#%s: %s", requestId, err)
@@ -116,7 +158,7 @@ func (this *RanServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
     // display 404 error
     if !context.exist {
         if this.config.Path404 != nil {
-            _, err = ErrorFile404(w, this.config.Path404.Abs)
+            _, err = this.errorFile(w, 404, this.config.Path404)
             if err != nil {
                 this.logger.Errorf("#%s: Load 404 file error: %s", requestId, err)
                 Error(w, 404)
@@ -127,7 +169,7 @@ func (this *RanServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
         return
     }
     if this.config.Path404 != nil && context.cleanPath == this.config.Path404.Rel {
-        _, err = ErrorFile404(w, this.config.Path404.Abs)
+        _, err = this.errorFile(w, 404, this.config.Path404)
         if err != nil {
             this.logger.Errorf("#%s: Load 404 file error: %s", requestId, err)
             Error(w, 404)
@@ -136,7 +178,10 @@ func (this *RanServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
 
     // display index page
     if context.indexPath != "" {
-        err := serveFile(w, r, context.absFilePath, !this.config.NoCache)
+        if !this.config.NoCache {
+            this.setCacheHeader(w, context.indexPath)
+        }
+        err := serveFile(w, r, this.fsys, context.name, !this.config.NoCache)
         if err != nil {
             Error(w, 500)
             this.logger.Errorf("#%s: %s", requestId, err)
@@ -158,7 +203,10 @@ func (this *RanServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
     }
 
     // serve the static file.
-    err = serveFile(w, r, context.absFilePath, !this.config.NoCache)
+    if !this.config.NoCache {
+        this.setCacheHeader(w, context.cleanPath)
+    }
+    err = serveFile(w, r, this.fsys, context.name, !this.config.NoCache)
     if err != nil {
         Error(w, 500)
         this.logger.Errorf("#%s: %s", requestId, err)
@@ -185,24 +233,162 @@ func randTime(n ...int64) int {
 }
 
 
-// make the request handler chain:
-// log -> authentication -> gzip -> original handler
-// TODO: add ip filter: log -> [ip filter] -> authentication -> gzip -> original handler
+// Serve returns the request handler of the site. The handler chain is made again by Reload() and SetConfig().
 func (this *RanServer) Serve() http.HandlerFunc {
+    this.handler.Store(this.current().makeHandler())
+
+    return func(w http.ResponseWriter, r *http.Request) {
+        requestId := string(getRequestId(r.URL.String()))
+        w.Header().Set("X-Request-Id", requestId)
+        this.setHSTSHeader(w, r)
+        this.handler.Load().(http.HandlerFunc)(w, r)
+    }
+}
+
+
+// ServeHTTP makes RanServer an http.Handler, requests are served by the handler returned by Serve.
+func (this *RanServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+    this.serveOnce.Do(func() {
+        this.serve = this.Serve()
+    })
+    this.serve(w, r)
+}
+
+
+// Reload makes the request handler chain again, so that files used by the handlers (like the custom 401 file)
+// are loaded again. States of the handlers (like the rate limit of clients) are reset.
+func (this *RanServer) Reload() {
+    this.handler.Store(this.current().makeHandler())
+}
+
+
+// SetConfig replaces the settings and the files of the site, e.g. after the config file is changed.
+// Requests being served keep the old settings, states of the handlers are reset like Reload().
+// Options of metrics, health checks, maintenance mode and live reload are not changed, they are decided by New.
+func (this *RanServer) SetConfig(c Config, fsys fs.FS) {
+    c.Metrics       = this.config.Metrics
+    c.Health        = this.config.Health
+    c.Maintenance   = this.config.Maintenance
+    c.LiveReload    = this.config.LiveReload
+
+    if len(c.Mounts) > 0 {
+        fsys = &mountFS{main: fsys, mounts: c.Mounts}
+    }
+
+    // the settings of this are never changed, because they are read by the handlers without locking
+    site := &RanServer {
+        config:         c,
+        fsys:           fsys,
+        logger:         this.logger,
+        metrics:        this.metrics,
+        certificate:    this.certificate,
+        maintenance:    this.maintenance,
+        liveReload:     this.liveReload,
+    }
+    this.site.Store(site)
+    this.handler.Store(site.makeHandler())
+}
+
+
+// current returns the RanServer which has the settings in use, it's this if SetConfig is never called.
+func (this *RanServer) current() *RanServer {
+    if site, ok := this.site.Load().(*RanServer); ok {
+        return site
+    }
+    return this
+}
+
+
+// make the request handler chain:
+// [health] -> log -> [health] -> maintenance -> rate limit -> client certificate -> [virtual hosts] -> [mounts]
+// -> site handler
+// health endpoints are before the log handler if their requests are not logged.
+// TODO: add ip filter: log -> [ip filter] -> rate limit -> ... -> original handler
+func (this *RanServer) makeHandler() http.HandlerFunc {
+
+    handler := this.makeSiteHandler()
+
+    // mount handler, requests under a mount point are sent to it's own site handler
+    if len(this.config.Mounts) > 0 {
+        handler = this.mountHandler(handler)
+    }
+
+    // virtual host handler, requests of a virtual host are sent to it's own site handler
+    if len(this.config.VirtualHosts) > 0 {
+        handler = this.vhostHandler(handler)
+    }
+
+    // client certificate handler
+    if this.config.ClientCert != nil {
+        handler = this.clientCertHandler(handler)
+    }
+
+    // rate limit handler
+    if this.config.RateLimit != nil {
+        handler = this.rateLimitHandler(handler)
+    }
+
+    // maintenance handler
+    handler = this.maintenanceHandler(handler)
+
+    // health handler, health endpoints are not protected
+    if this.config.Health != nil && !this.config.Health.NoLog {
+        handler = this.healthHandler(handler)
+    }
+
+    // log handler
+    handler = this.logHandler(handler)
+
+    if this.config.Health != nil && this.config.Health.NoLog {
+        handler = this.healthHandler(handler)
+    }
+
+    return handler
+}
+
+
+// make the request handler chain of a site:
+// [headers] -> authentication -> metrics -> live reload -> proxy -> gzip -> live reload script -> original handler
+func (this *RanServer) makeSiteHandler() http.HandlerFunc {
 
     // original ran server handler
     handler := this.serveHTTP
 
+    // inject the live reload script into HTML files, before they are compressed
+    if this.liveReload != nil {
+        handler = injectScriptHandler(handler)
+    }
+
     // gzip handler
     if this.config.Gzip {
+        if this.metrics != nil {
+            handler = countUncompressed(handler)
+        }
         handler = hhelper.GzipHandler(handler, true, true)
     }
 
+    // proxy handler, requests matching a proxy route will not reach the gzip and original handler.
+    if this.config.Proxy != nil {
+        handler = this.proxyHandler(handler)
+    }
+
+    // live reload handler, the event stream is not compressed
+    if this.liveReload != nil {
+        handler = this.liveReloadHandler(handler)
+    }
+
+    // metrics handler, metrics are protected by authentication if they are served on the site
+    if this.config.Metrics != nil && !this.config.Metrics.Separate {
+        handler = this.metricsHandler(handler)
+    }
+
     // authentication handler
     if this.config.Auth != nil {
         realm := "Identity authentication"
 
         failFunc := func() {
+            this.metrics.authFailed(string(this.config.Auth.Method))
+
             // sleep 300~2499 milliseconds to prevent brute force attack
             time.Sleep(time.Duration(randTime()) * time.Millisecond)
         }
@@ -212,7 +398,7 @@ func (this *RanServer) Serve() http.HandlerFunc {
         // load custom 401 file
         if this.config.Path401 != nil {
             var err error
-            authFile, err = errorFile401(this.config)
+            authFile, err = this.errorFile401()
             if err != nil {
                 this.logger.Errorf("Load 401 file error: %s", err)
             }
@@ -246,18 +432,36 @@ func (this *RanServer) Serve() http.HandlerFunc {
         }
     }
 
-    // log handler
-    handler = this.logHandler(handler)
-
-    return func(w http.ResponseWriter, r *http.Request) {
-        handler(w, r)
+    // header handler, headers are also set to the responses of authentication failures
+    if len(this.config.Header) > 0 {
+        handler = this.headerHandler(handler)
     }
+
+    return handler
 }
 
 
-// redirect to https page
+// redirect to https page.
+// if HSTS is on, the redirect is permanent (301) instead of temporary (307), as required by the HSTS preload list.
 func (this *RanServer) RedirectToHTTPS(port uint) http.HandlerFunc {
-    handler := this.logHandler(hhelper.RedirectToHTTPS(port))
+    code := http.StatusTemporaryRedirect
+    if this.config.HSTS != nil {
+        code = http.StatusMovedPermanently
+    }
+
+    redirect := func(w http.ResponseWriter, r *http.Request) {
+        host := r.Host
+        if h, _, err := net.SplitHostPort(r.Host); err == nil {
+            host = h
+        }
+        base := url.URL {
+            Scheme: "https",
+            Host:   net.JoinHostPort(strings.Trim(host, "[]"), strconv.Itoa(int(port))),
+        }
+        http.Redirect(w, r, base.ResolveReference(r.URL).String(), code)
+    }
+
+    handler := this.logHandler(redirect)
     return func(w http.ResponseWriter, r *http.Request) {
         requestId := string(getRequestId(r.URL.String()))
         w.Header().Set("X-Request-Id", requestId)
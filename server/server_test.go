@@ -0,0 +1,219 @@
+package server
+
+import "io"
+import "io/fs"
+import "net/http"
+import "net/http/httptest"
+import "os"
+import "path/filepath"
+import "strings"
+import "testing"
+import "testing/fstest"
+
+
+func get(t *testing.T, h http.Handler, target string) (int, string) {
+    t.Helper()
+    w := httptest.NewRecorder()
+    h.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+    b, err := io.ReadAll(w.Result().Body)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return w.Code, string(b)
+}
+
+
+func TestSetConfig(t *testing.T) {
+    var roots []string
+    for _, content := range []string{"one", "two"} {
+        root := t.TempDir()
+        if err := os.WriteFile(filepath.Join(root, "a.txt"), []byte(content), 0644); err != nil {
+            t.Fatal(err)
+        }
+        roots = append(roots, root)
+    }
+
+    c := Config{Root: roots[0], IndexName: Index{"index.html"}}
+    ran := NewRanServer(c, newTestLogger(t))
+    handler := ran.Serve()
+
+    if code, body := get(t, handler, "/a.txt"); code != 200 || body != "one" {
+        t.Fatalf("Before SetConfig: got %d %q, want 200 \"one\"", code, body)
+    }
+
+    c.Root = roots[1]
+    c.Auth = &Auth{Method: BasicMethod, Username: "admin", Password: "secret"}
+    ran.SetConfig(c, os.DirFS(roots[1]))
+
+    r := httptest.NewRequest("GET", "/a.txt", nil)
+    r.SetBasicAuth("admin", "secret")
+    w := httptest.NewRecorder()
+    handler(w, r)
+    if w.Code != 200 || w.Body.String() != "two" {
+        t.Fatalf("After SetConfig: got %d %q, want 200 \"two\"", w.Code, w.Body.String())
+    }
+
+    // maintenance mode is shared with the new settings
+    ran.SetMaintenance(true)
+    if code, _ := get(t, handler, "/a.txt"); code != http.StatusServiceUnavailable {
+        t.Errorf("In maintenance mode: got %d, want 503", code)
+    }
+}
+
+
+var testSite = fstest.MapFS {
+    "index.html":       {Data: []byte("home")},
+    "docs/index.htm":   {Data: []byte("docs")},
+    "docs/guide.txt":   {Data: []byte("guide")},
+    "files/a.txt":      {Data: []byte("a")},
+    "files/b.txt":      {Data: []byte("b")},
+    "empty/.keep":      {Data: []byte{}},
+    ".secret":          {Data: []byte("secret")},
+    "404.html":         {Data: []byte("custom 404")},
+    "401.html":         {Data: []byte("custom 401")},
+}
+
+
+func TestNewServeFS(t *testing.T) {
+    ran := New(testSite, WithListDir(true), With404("/404.html"))
+
+    tests := []struct {
+        target      string
+        code        int
+        body        string  // body or location of a redirect
+    }{
+        {"/", 200, "home"},
+        {"/index.html?v=1", 200, "home"},
+        {"/docs", 307, "/docs/"},
+        {"/docs/", 200, "docs"},
+        {"/docs/guide.txt", 200, "guide"},
+        {"/docs/../docs/guide.txt", 307, "/docs/guide.txt"},
+        {"/docs//guide.txt?v=1", 307, "/docs/guide.txt?v=1"},
+        {"/files", 307, "/files/"},
+        {"/missing.txt", 404, "custom 404"},
+        {"/.secret", 404, "custom 404"},
+    }
+
+    for _, test := range tests {
+        w := httptest.NewRecorder()
+        ran.ServeHTTP(w, httptest.NewRequest("GET", test.target, nil))
+        if w.Code != test.code {
+            t.Errorf("%s: status is %d, want %d", test.target, w.Code, test.code)
+            continue
+        }
+        got := w.Body.String()
+        if w.Code == 307 {
+            got = w.Header().Get("Location")
+        }
+        if got != test.body {
+            t.Errorf("%s: got %q, want %q", test.target, got, test.body)
+        }
+    }
+
+    code, body := get(t, ran, "/files/")
+    if code != 200 || !strings.Contains(body, "a.txt") || !strings.Contains(body, "b.txt") {
+        t.Errorf("Listing of /files/: got %d %q", code, body)
+    }
+    if _, body = get(t, ran, "/empty/"); strings.Contains(body, ".keep") {
+        t.Errorf("Listing of /empty/ should not show paths start with dot: %q", body)
+    }
+
+    // directories without an index file are not found if ListDir is false
+    if code, _ := get(t, New(testSite), "/files/"); code != 404 {
+        t.Errorf("/files/ without ListDir: status is %d, want 404", code)
+    }
+}
+
+
+func TestNewIndexNames(t *testing.T) {
+    ran := New(testSite, WithIndexNames("index.htm"))
+    if code, body := get(t, ran, "/docs/"); code != 200 || body != "docs" {
+        t.Errorf("/docs/: got %d %q", code, body)
+    }
+    if code, _ := get(t, ran, "/"); code != 404 {
+        t.Errorf("/ without index.html in the index names: status is %d, want 404", code)
+    }
+}
+
+
+func TestNew401File(t *testing.T) {
+    ran := New(testSite, WithAuth(BasicMethod, "admin", "secret"), With401("/401.html"))
+
+    code, body := get(t, ran, "/")
+    if code != 401 || body != "custom 401" {
+        t.Errorf("Without credentials: got %d %q, want 401 \"custom 401\"", code, body)
+    }
+
+    r := httptest.NewRequest("GET", "/", nil)
+    r.SetBasicAuth("admin", "secret")
+    w := httptest.NewRecorder()
+    ran.ServeHTTP(w, r)
+    if w.Code != 200 || w.Body.String() != "home" {
+        t.Errorf("With credentials: got %d %q, want 200 \"home\"", w.Code, w.Body.String())
+    }
+}
+
+
+// noSeekFS hides the Seek method of the files, like files of a compressed archive.
+type noSeekFS struct {
+    fs.FS
+}
+
+
+type noSeekFile struct {
+    fs.File
+}
+
+
+func (this noSeekFS) Open(name string) (fs.File, error) {
+    f, err := this.FS.Open(name)
+    if err != nil {
+        return nil, err
+    }
+    return noSeekFile{f}, nil
+}
+
+
+func TestServeNonSeekableFile(t *testing.T) {
+    ran := New(noSeekFS{testSite})
+
+    if code, body := get(t, ran, "/docs/guide.txt"); code != 200 || body != "guide" {
+        t.Errorf("got %d %q, want 200 \"guide\"", code, body)
+    }
+
+    r := httptest.NewRequest("GET", "/docs/guide.txt", nil)
+    r.Header.Set("Range", "bytes=1-3")
+    w := httptest.NewRecorder()
+    ran.ServeHTTP(w, r)
+    if w.Code != http.StatusPartialContent || w.Body.String() != "uid" {
+        t.Errorf("Range request: got %d %q, want 206 \"uid\"", w.Code, w.Body.String())
+    }
+}
+
+
+func TestNewRanServer(t *testing.T) {
+    root := t.TempDir()
+    for name, content := range map[string]string{"index.html": "home", "404.html": "custom 404"} {
+        if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0644); err != nil {
+            t.Fatal(err)
+        }
+    }
+
+    c := Config {
+        Root:       root,
+        IndexName:  Index{"index.html"},
+        Path404:    &ErrorFilePath{Abs: filepath.Join(root, "404.html"), Rel: "/404.html"},
+    }
+    ran := NewRanServer(c, newTestLogger(t))
+
+    // the handler of Serve and ServeHTTP serve the same files
+    if code, body := get(t, ran.Serve(), "/"); code != 200 || body != "home" {
+        t.Errorf("Serve(): got %d %q", code, body)
+    }
+    if code, body := get(t, ran, "/"); code != 200 || body != "home" {
+        t.Errorf("ServeHTTP(): got %d %q", code, body)
+    }
+    if code, body := get(t, ran, "/missing"); code != 404 || body != "custom 404" {
+        t.Errorf("Custom 404 file: got %d %q", code, body)
+    }
+}
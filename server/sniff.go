@@ -0,0 +1,129 @@
+package server
+
+import "bufio"
+import "net"
+import "sync"
+import "time"
+
+
+// the first byte of a TLS handshake record
+const tlsHandshakeByte = 0x16
+
+// how long to wait for the first byte of a new connection
+const sniffTimeout = 10 * time.Second
+
+
+// SplitListener splits a listener by protocol, so that HTTP and HTTPS could be served on the same port.
+// The first byte of every new connection is peeked, TLS handshakes are sent to tlsListener,
+// and other connections are sent to plainListener. Closing either of them closes the underlying listener.
+func SplitListener(l net.Listener) (tlsListener, plainListener net.Listener) {
+    s := &splitter {
+        Listener:   l,
+        done:       make(chan struct{}),
+    }
+    tlsL := &subListener{splitter: s, conns: make(chan net.Conn)}
+    plainL := &subListener{splitter: s, conns: make(chan net.Conn)}
+
+    go s.serve(tlsL, plainL)
+
+    return tlsL, plainL
+}
+
+
+type splitter struct {
+    net.Listener
+    done        chan struct{}   // closed when the underlying listener stops
+    err         error           // error returned by the underlying listener
+    closeOnce   sync.Once
+}
+
+
+func (this *splitter) serve(tlsL, plainL *subListener) {
+    var delay time.Duration
+
+    for {
+        c, err := this.Listener.Accept()
+        if err != nil {
+            // retry on temporary errors like "too many open files", as http.Server does
+            if ne, ok := err.(net.Error); ok && ne.Temporary() {
+                if delay == 0 {
+                    delay = 5 * time.Millisecond
+                } else if delay *= 2; delay > time.Second {
+                    delay = time.Second
+                }
+                time.Sleep(delay)
+                continue
+            }
+            this.stop(err)
+            return
+        }
+        delay = 0
+
+        // peek in a new goroutine, so a slow client does not block others
+        go func(c net.Conn) {
+            pc := &peekConn{Conn: c, reader: bufio.NewReader(c)}
+
+            c.SetReadDeadline(time.Now().Add(sniffTimeout))
+            first, err := pc.reader.Peek(1)
+            c.SetReadDeadline(time.Time{})
+            if err != nil {
+                c.Close()
+                return
+            }
+
+            target := plainL
+            if first[0] == tlsHandshakeByte {
+                target = tlsL
+            }
+
+            select {
+                case target.conns <- pc:
+                case <-this.done:
+                    c.Close()
+            }
+        }(c)
+    }
+}
+
+
+func (this *splitter) stop(err error) {
+    this.closeOnce.Do(func() {
+        this.err = err
+        close(this.done)
+    })
+}
+
+
+func (this *splitter) Close() error {
+    err := this.Listener.Close()
+    this.stop(net.ErrClosed)
+    return err
+}
+
+
+type subListener struct {
+    *splitter
+    conns chan net.Conn
+}
+
+
+func (this *subListener) Accept() (net.Conn, error) {
+    select {
+        case c := <-this.conns:
+            return c, nil
+        case <-this.done:
+            return nil, this.err
+    }
+}
+
+
+// peekConn is a connection whose first bytes have been read to a buffer.
+type peekConn struct {
+    net.Conn
+    reader *bufio.Reader
+}
+
+
+func (this *peekConn) Read(b []byte) (int, error) {
+    return this.reader.Read(b)
+}
@@ -0,0 +1,61 @@
+package server
+
+import "io"
+import "net"
+import "testing"
+import "time"
+
+
+func TestSplitListener(t *testing.T) {
+    l, err := net.Listen("tcp", "127.0.0.1:0")
+    if err != nil {
+        t.Fatal(err)
+    }
+    tlsL, plainL := SplitListener(l)
+    defer tlsL.Close()
+
+    tests := []struct {
+        data    string
+        tls     bool
+    }{
+        {"\x16\x03\x01", true},
+        {"GET / HTTP/1.1\r\n", false},
+        {"PRI * HTTP/2.0\r\n", false},
+        {"\x80\x2e\x01", false},
+    }
+
+    for _, test := range tests {
+        c, err := net.Dial("tcp", l.Addr().String())
+        if err != nil {
+            t.Fatal(err)
+        }
+        if _, err = c.Write([]byte(test.data)); err != nil {
+            t.Fatal(err)
+        }
+
+        expected := plainL
+        if test.tls {
+            expected = tlsL
+        }
+
+        accepted := make(chan net.Conn, 1)
+        go func() {
+            if conn, err := expected.Accept(); err == nil {
+                accepted <- conn
+            }
+        }()
+
+        select {
+            case conn := <-accepted:
+                // the peeked byte should still be read
+                buf := make([]byte, len(test.data))
+                if _, err := io.ReadFull(conn, buf); err != nil || string(buf) != test.data {
+                    t.Errorf("%q: read %q, %v", test.data, buf, err)
+                }
+                conn.Close()
+            case <-time.After(2 * time.Second):
+                t.Fatalf("%q: connection is not accepted by the expected listener, tls: %t", test.data, test.tls)
+        }
+        c.Close()
+    }
+}
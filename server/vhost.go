@@ -0,0 +1,283 @@
+package server
+
+import "fmt"
+import "io/fs"
+import "net"
+import "net/http"
+import "os"
+import "strconv"
+import "strings"
+
+
+// VirtualHost serves the requests of some host names from a separate root, with it's own settings.
+// Settings not in VirtualHost (e.g. gzip, cache policy and CORS) are the same as the main site,
+// proxy routes and metrics are not served on a virtual host.
+type VirtualHost struct {
+    Hosts       []string        // Host names, a name starts with "*." matches all the sub domains, e.g. *.example.com.
+    Root        string          // Root path of the virtual host.
+    Files       fs.FS           // Files of the virtual host. nil means os.DirFS(Root).
+    IndexName   Index           // File name of index. Default is []string{"index.html", "index.htm"}.
+    ListDir     bool            // If no index file provide, show file list of the directory.
+    ServeAll    bool            // If is false, path start with dot will not be served.
+    Auth        *Auth           // If not nil, turn on authentication.
+    Path404     *ErrorFilePath  // Path of custom 404 file, under directory of Root. nil means do not use 404 file.
+    Path401     *ErrorFilePath  // Path of custom 401 file, under directory of Root. nil means do not use 401 file.
+    Header      Header          // Headers set to the responses.
+    Default     bool            // If true, requests of the hosts not matched by any virtual host are served by it,
+                                // instead of the main site.
+    ErrorFile404 string         // Value of the 404 option, it's checked and converted to Path404 by the caller.
+    ErrorFile401 string         // Value of the 401 option, it's checked and converted to Path401 by the caller.
+}
+
+
+func (this *VirtualHost) String() string {
+    s := fmt.Sprintf("%s=%s (index: %s, listdir: %t, serve all: %t",
+        strings.Join(this.Hosts, ","), this.Root, strings.Join(this.IndexName, ", "), this.ListDir, this.ServeAll)
+    if this.Auth != nil {
+        s += ", auth: " + string(this.Auth.Method)
+    }
+    if this.Path404 != nil {
+        s += ", 404: " + this.Path404.Rel
+    }
+    if this.Path401 != nil {
+        s += ", 401: " + this.Path401.Rel
+    }
+    if len(this.Header) > 0 {
+        s += ", headers: " + this.Header.String()
+    }
+    if this.Default {
+        s += ", default"
+    }
+    return s + ")"
+}
+
+
+// VirtualHosts is a list of VirtualHost, it could be used as a repeatable command-line flag.
+type VirtualHosts []*VirtualHost
+
+
+func (this *VirtualHosts) String() string {
+    var s []string
+    for _, vh := range *this {
+        s = append(s, vh.String())
+    }
+    return strings.Join(s, "; ")
+}
+
+
+// Set parses a value like "<hosts>=<root>[;<option>...]", host names are separated by comma. Options are:
+//
+//  index=<names>           file names of index, separate by colon
+//  listdir[=<bool>]        show file list of a directory
+//  serve-all[=<bool>]      serve paths start with dot
+//  auth=<user:pass>        username and password of authentication
+//  auth-method=<method>    authentication method, basic or digest
+//  404=<path>              path of a custom 404 file, relative to the root
+//  401=<path>              path of a custom 401 file, relative to the root
+//  header=<name: value>    header set to the responses, could be used more than once
+//  default                 serve the requests of the hosts not matched by any virtual host
+//
+// e.g. "docs.example.com,*.docs.example.com=/srv/docs;listdir;header=X-Frame-Options: DENY"
+func (this *VirtualHosts) Set(value string) error {
+    pair := strings.SplitN(value, "=", 2)
+    if len(pair) != 2 || strings.TrimSpace(pair[0]) == "" {
+        return fmt.Errorf("Virtual host should be in the form of <hosts>=<root>[;<option>...], got '%s'", value)
+    }
+
+    vh := &VirtualHost{IndexName: Index{"index.html", "index.htm"}, Header: Header{}}
+
+    for _, host := range strings.Split(pair[0], ",") {
+        host = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(host), "."))
+        if host == "" {
+            continue
+        }
+        name := strings.TrimPrefix(host, "*.")
+        if name == "" || strings.ContainsAny(name, "*/:") {
+            return fmt.Errorf("Invalid host name of virtual host '%s'", host)
+        }
+        vh.Hosts = append(vh.Hosts, host)
+    }
+
+    options := strings.Split(pair[1], ";")
+    vh.Root = strings.TrimSpace(options[0])
+    if vh.Root == "" {
+        return fmt.Errorf("Root of virtual host '%s' should be provided", pair[0])
+    }
+
+    var method AuthMethod = BasicMethod
+    for _, option := range options[1:] {
+        kv := strings.SplitN(option, "=", 2)
+        key := strings.TrimSpace(kv[0])
+        v := ""
+        if len(kv) == 2 {
+            v = strings.TrimSpace(kv[1])
+        }
+
+        var err error
+        switch key {
+            case "":
+                continue
+            case "index":
+                err = vh.IndexName.Set(v)
+            case "listdir":
+                vh.ListDir, err = parseOptionBool(v)
+            case "serve-all":
+                vh.ServeAll, err = parseOptionBool(v)
+            case "default":
+                vh.Default, err = parseOptionBool(v)
+            case "auth":
+                vh.Auth, err = parseAuthOption(v)
+            case "auth-method":
+                method, err = parseAuthMethodOption(v)
+            case "404":
+                vh.ErrorFile404 = v
+            case "401":
+                vh.ErrorFile401 = v
+            case "header":
+                err = vh.Header.Set(v)
+            default:
+                err = fmt.Errorf("Unknown option '%s'", key)
+        }
+        if err != nil {
+            return fmt.Errorf("Virtual host '%s': %s", pair[0], err)
+        }
+    }
+
+    if vh.Auth != nil {
+        vh.Auth.Method = method
+    }
+
+    *this = append(*this, vh)
+    return nil
+}
+
+
+// an option without a value is true, e.g. "listdir" is the same as "listdir=true"
+func parseOptionBool(v string) (bool, error) {
+    if v == "" {
+        return true, nil
+    }
+    return strconv.ParseBool(v)
+}
+
+
+// parse an auth option like "user:pass", the method is basic
+func parseAuthOption(v string) (*Auth, error) {
+    user := strings.SplitN(v, ":", 2)
+    if len(user) != 2 || user[0] == "" || user[1] == "" {
+        return nil, fmt.Errorf("Auth should be in the form of <username>:<password>")
+    }
+    return &Auth{Username: user[0], Password: user[1], Method: BasicMethod}, nil
+}
+
+
+// parse an auth-method option, basic or digest
+func parseAuthMethodOption(v string) (AuthMethod, error) {
+    method := AuthMethod(strings.ToLower(v))
+    if method != BasicMethod && method != DigestMethod {
+        return method, fmt.Errorf("Invalid authentication method '%s'", v)
+    }
+    return method, nil
+}
+
+
+// hostName removes the port and the trailing dot of a Host header, and converts it to lower case.
+func hostName(host string) string {
+    if h, _, err := net.SplitHostPort(host); err == nil {
+        host = h
+    }
+    return strings.ToLower(strings.TrimSuffix(strings.Trim(host, "[]"), "."))
+}
+
+
+// matchVirtualHost finds the virtual host of a Host header and returns the matched host name.
+// An exact host name has higher priority than a wildcard, and a longer wildcard has higher priority than a shorter one.
+// If no virtual host matches, the default virtual host is returned with the name "default",
+// if there is no default virtual host, nil is returned and the request is served by the main site.
+func matchVirtualHost(hosts []*VirtualHost, host string) (*VirtualHost, string) {
+    host = hostName(host)
+
+    var matched, def *VirtualHost
+    var name string
+    for _, vh := range hosts {
+        if vh.Default && def == nil {
+            def = vh
+        }
+        for _, h := range vh.Hosts {
+            if h == host {
+                return vh, h
+            }
+            if strings.HasPrefix(h, "*.") && strings.HasSuffix(host, h[1:]) && len(h) > len(name) {
+                matched, name = vh, h
+            }
+        }
+    }
+
+    if matched != nil {
+        return matched, name
+    }
+    if def != nil {
+        return def, "default"
+    }
+    return nil, ""
+}
+
+
+// virtualHostServer creates a RanServer which serves the files of a virtual host, other settings are copied from this.
+func (this *RanServer) virtualHostServer(vh *VirtualHost) *RanServer {
+    c := this.config
+    c.Root = vh.Root
+    c.IndexName = vh.IndexName
+    if len(c.IndexName) == 0 {
+        c.IndexName = Index{"index.html", "index.htm"}
+    }
+    c.ListDir = vh.ListDir
+    c.ServeAll = vh.ServeAll
+    c.Auth = vh.Auth
+    c.Path404 = vh.Path404
+    c.Path401 = vh.Path401
+    c.Header = vh.Header
+
+    fsys := vh.Files
+    if fsys == nil {
+        fsys = os.DirFS(vh.Root)
+    }
+    return this.siteServer(c, fsys)
+}
+
+
+// siteServer creates a RanServer of a virtual host or a mount, which serves the files of fsys with the settings of c.
+// Proxy routes and metrics are served by the main site only, so they are never exposed without the auth of the main site.
+func (this *RanServer) siteServer(c Config, fsys fs.FS) *RanServer {
+    c.Proxy = nil
+    c.Metrics = nil
+    c.VirtualHosts = nil
+    c.Mounts = nil
+
+    return &RanServer {
+        config:         c,
+        fsys:           fsys,
+        logger:         this.logger,
+        metrics:        this.metrics,
+        certificate:    this.certificate,
+        maintenance:    this.maintenance,
+    }
+}
+
+
+// vhostHandler sends the requests of virtual hosts to their own handlers, other requests are sent to fn.
+func (this *RanServer) vhostHandler(fn http.HandlerFunc) http.HandlerFunc {
+    sites := make(map[*VirtualHost]http.HandlerFunc)
+    for _, vh := range this.config.VirtualHosts {
+        sites[vh] = this.virtualHostServer(vh).makeSiteHandler()
+    }
+
+    return func(w http.ResponseWriter, r *http.Request) {
+        vh, _ := matchVirtualHost(this.config.VirtualHosts, r.Host)
+        if vh == nil {
+            fn(w, r)
+            return
+        }
+        sites[vh](w, r)
+    }
+}
@@ -0,0 +1,110 @@
+package server
+
+import "net/http"
+import "net/http/httptest"
+import "sync/atomic"
+import "testing"
+import "testing/fstest"
+import "time"
+
+
+func TestMatchVirtualHost(t *testing.T) {
+    var hosts VirtualHosts
+    for _, value := range []string{
+        "example.com,www.example.com=/srv/www",
+        "*.example.com=/srv/sub",
+        "*.docs.example.com,docs.example.com=/srv/docs",
+        "Static.Example.org.=/srv/static",
+    } {
+        if err := hosts.Set(value); err != nil {
+            t.Fatalf("Set(%q): %s", value, err)
+        }
+    }
+
+    tests := []struct {
+        host    string
+        root    string
+        name    string
+    }{
+        {"example.com", "/srv/www", "example.com"},
+        {"www.example.com", "/srv/www", "www.example.com"},
+        {"WWW.Example.COM", "/srv/www", "www.example.com"},
+        {"example.com:8080", "/srv/www", "example.com"},
+        {"example.com.", "/srv/www", "example.com"},
+        {"www.example.com.:443", "/srv/www", "www.example.com"},
+        {"blog.example.com", "/srv/sub", "*.example.com"},
+        {"a.b.example.com", "/srv/sub", "*.example.com"},
+        {"docs.example.com", "/srv/docs", "docs.example.com"},
+        {"v2.docs.example.com", "/srv/docs", "*.docs.example.com"},
+        {"static.example.org", "/srv/static", "static.example.org"},
+        {"notexample.com", "", ""},
+        {"example.org", "", ""},
+        {"127.0.0.1:8080", "", ""},
+        {"[::1]:8080", "", ""},
+        {"", "", ""},
+    }
+
+    for _, test := range tests {
+        vh, name := matchVirtualHost(hosts, test.host)
+        root := ""
+        if vh != nil {
+            root = vh.Root
+        }
+        if root != test.root || name != test.name {
+            t.Errorf("matchVirtualHost(%q) = %q, %q, want %q, %q", test.host, root, name, test.root, test.name)
+        }
+    }
+
+    if err := hosts.Set("fallback.example.net=/srv/fallback;default"); err != nil {
+        t.Fatal(err)
+    }
+    for _, host := range []string{"example.org", "127.0.0.1:8080"} {
+        if vh, name := matchVirtualHost(hosts, host); vh == nil || vh.Root != "/srv/fallback" || name != "default" {
+            t.Errorf("matchVirtualHost(%q) should return the default virtual host, got %v, %q", host, vh, name)
+        }
+    }
+    if vh, _ := matchVirtualHost(hosts, "blog.example.com"); vh == nil || vh.Root != "/srv/sub" {
+        t.Errorf("A matched virtual host should have higher priority than the default one")
+    }
+}
+
+
+func TestVirtualHostProxy(t *testing.T) {
+    var hits int32
+    backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        atomic.AddInt32(&hits, 1)
+    }))
+    defer backend.Close()
+
+    var routes ProxyRoutes
+    if err := routes.Set("/api=" + backend.URL); err != nil {
+        t.Fatal(err)
+    }
+
+    var hosts VirtualHosts
+    for _, value := range []string{"open.test=/srv/open", "fallback.test=/srv/fallback;default"} {
+        if err := hosts.Set(value); err != nil {
+            t.Fatal(err)
+        }
+    }
+    for _, vh := range hosts {
+        vh.Files = fstest.MapFS{"index.html": {Data: []byte("open")}}
+    }
+
+    ran := New(fstest.MapFS{}, WithConfig(Config{
+        IndexName:      Index{"index.html"},
+        Auth:           &Auth{Method: BasicMethod, Username: "admin", Password: "secret"},
+        Proxy:          &Proxy{Routes: routes, Timeout: time.Second},
+        VirtualHosts:   hosts,
+    }))
+
+    // virtual hosts without auth, and the default virtual host of unknown hosts, e.g. the IP address
+    for _, host := range []string{"open.test", "127.0.0.1"} {
+        r := httptest.NewRequest("GET", "http://" + host + "/api/secret", nil)
+        w := httptest.NewRecorder()
+        ran.ServeHTTP(w, r)
+        if w.Code == http.StatusOK || atomic.LoadInt32(&hits) != 0 {
+            t.Errorf("Host %s: request of a proxy route reaches the backend without auth, status: %d", host, w.Code)
+        }
+    }
+}
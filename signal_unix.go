@@ -0,0 +1,14 @@
+//go:build !windows
+
+package main
+
+import "os"
+import "syscall"
+
+
+// signals which turn on or off maintenance mode
+var maintenanceSignals = []os.Signal{syscall.SIGUSR1}
+
+
+// signals which reload the config and the certificates
+var reloadSignals = []os.Signal{syscall.SIGHUP}
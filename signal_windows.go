@@ -0,0 +1,11 @@
+package main
+
+import "os"
+
+
+// SIGUSR1 is not supported on Windows, maintenance mode could not be turned on or off by signals.
+var maintenanceSignals []os.Signal
+
+
+// SIGHUP is not sent on Windows, the config and the certificates could be reloaded by the admin API.
+var reloadSignals []os.Signal